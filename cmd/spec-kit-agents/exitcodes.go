@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/install"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// Exit codes. These are part of the documented interface (see README) and
+// must not be renumbered.
+const (
+	exitOK                     = 0
+	exitError                  = 1
+	exitUsage                  = 2
+	exitNotInstalled           = 3
+	exitAlreadyInstalled       = 4
+	exitIncompatible           = 5
+	exitDriftDetected          = 6
+	exitBackupNotFound         = 7
+	exitLocked                 = 8
+	exitIntegrityMismatch      = 9
+	exitConflicts              = 10
+	exitLockVersionUnsupported = 11
+	exitBackupCorrupt          = 12
+)
+
+// errUsage is matched by usageError
+var errUsage = errors.New("invalid usage")
+
+// usageError marks invalid command-line arguments without changing the
+// message
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// Is makes errors.Is(err, errUsage) true for a usageError
+func (e *usageError) Is(target error) bool {
+	return target == errUsage
+}
+
+// errConfirmationRequired is returned instead of prompting when the output
+// is meant for a script
+var errConfirmationRequired = errors.New("confirmation required: rerun with --force")
+
+// failureClasses maps the failure classes of the install and version
+// packages to the error code used in structured output and the exit code.
+// The first match wins.
+var failureClasses = []struct {
+	err      error
+	code     string
+	exitCode int
+}{
+	{errConfirmationRequired, "confirmation_required", exitUsage},
+	{errUsage, "usage", exitUsage},
+	{install.ErrNotInstalled, "not_installed", exitNotInstalled},
+	{install.ErrAlreadyInstalled, "already_installed", exitAlreadyInstalled},
+	{version.ErrIncompatible, "incompatible", exitIncompatible},
+	{install.ErrDriftDetected, "drift_detected", exitDriftDetected},
+	{install.ErrBackupNotFound, "backup_not_found", exitBackupNotFound},
+	{install.ErrBackupCorrupt, "backup_corrupt", exitBackupCorrupt},
+	{install.ErrLocked, "locked", exitLocked},
+	{version.ErrIntegrityMismatch, "integrity_mismatch", exitIntegrityMismatch},
+	{install.ErrConflicts, "conflicts", exitConflicts},
+	{models.ErrUnsupportedLockVersion, "lock_version_unsupported", exitLockVersionUnsupported},
+}
+
+// classifyError returns the error code and exit code for a failed command
+func classifyError(err error) (string, int) {
+	for _, class := range failureClasses {
+		if errors.Is(err, class.err) {
+			return class.code, class.exitCode
+		}
+	}
+	return "error", exitError
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
 	"github.com/dkoenawan/claude-agent-templates/internal/install"
@@ -17,30 +20,67 @@ var (
 	GitCommit = "unknown"
 
 	// Global flags
-	verbose bool
-	quiet   bool
+	verbose      bool
+	quiet        bool
+	outputFormat string
+	logFile      string
+	logFormat    string
 
 	// Command-specific flags
 	installPrefix string
 	installGlobal bool
 	installForce  bool
 	installDryRun bool
+	skipIntegrity bool
+	sourceDir     string
+	lockWait      time.Duration
 
 	// Update command flags
 	updateNoBackup  bool
 	updateForce     bool
 	updateSkipVerify bool
+	updateOnConflict string
 
 	// Rollback command flags
 	rollbackBackupID string
 	rollbackList     bool
 	rollbackForce    bool
+
+	// Uninstall command flags
+	uninstallDryRun bool
+	uninstallBackup bool
+	uninstallForce  bool
+
+	// Verify command flags
+	verifyDeep bool
+
+	// Logs command flags
+	logsComponent string
+	logsOperation string
+	logsLines     int
+	logsFollow    bool
+
+	// Backup retention flags, shared by update and backup prune
+	backupKeepLast   int
+	backupKeepWithin time.Duration
+	backupMaxSize    string
+
+	// Backup command flags
+	pruneDryRun        bool
+	pruneIncludePinned bool
+	backupLabel        string
 )
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		var reported *reportedError
+		if !structuredOutput() {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		} else if !errors.As(err, &reported) {
+			writeError(os.Stdout, err)
+		}
+		_, exitCode := classifyError(err)
+		os.Exit(exitCode)
 	}
 }
 
@@ -54,6 +94,23 @@ This tool ensures that claude-agent-templates and spec-kit are installed togethe
 with compatible, pinned versions to prevent breaking changes from uncontrolled
 spec-kit upgrades.`,
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+		if logFormat == "" {
+			logFormat = os.Getenv(config.LogFormatEnvVar)
+		}
+		if logFormat == "" {
+			logFormat = config.LogFormatText
+		}
+		if _, err := config.ParseLogFormat(logFormat); err != nil {
+			return &usageError{err}
+		}
+		// Errors are written as part of the structured output instead
+		cmd.Root().SilenceErrors = structuredOutput()
+		return nil
+	},
 }
 
 var installCmd = &cobra.Command{
@@ -75,12 +132,15 @@ Examples:
   # Install to custom directory
   spec-kit-agents install --prefix /path/to/dir
 
-  # Install globally to ~/.claude/agents/
+  # Install globally to ~/spec-kit-agents (shared by all projects)
   spec-kit-agents install --global
 
   # Force reinstall (overwrite existing)
   spec-kit-agents install --force
 
+  # Install from a checkout outside the current directory
+  spec-kit-agents install --source ~/src/claude-agent-templates
+
   # Dry run (show what would be done)
   spec-kit-agents install --dry-run`,
 	RunE: runInstall,
@@ -101,18 +161,39 @@ Examples:
   spec-kit-agents status
 
   # Show status for specific prefix
-  spec-kit-agents status --prefix /path/to/installation`,
+  spec-kit-agents status --prefix /path/to/installation
+
+  # Show status of the global installation
+  spec-kit-agents status --global`,
 	RunE: runStatus,
 }
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show version information",
-	Long:  "Display version information for the spec-kit-agents tool.",
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Display version information for the spec-kit-agents tool and the
+agents and spec-kit payload embedded in it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if structuredOutput() {
+			output := VersionOutput{Version: Version, BuildTime: BuildTime, GitCommit: GitCommit}
+			if payload, err := install.GetPayloadInfo(); err == nil {
+				output.Payload = payload
+			}
+			return writeResult(os.Stdout, output)
+		}
+
 		fmt.Printf("spec-kit-agents version %s\n", Version)
 		fmt.Printf("  Build time: %s\n", BuildTime)
 		fmt.Printf("  Git commit: %s\n", GitCommit)
+
+		payload, err := install.GetPayloadInfo()
+		if err != nil {
+			fmt.Printf("  Payload: unavailable (%v)\n", err)
+			return nil
+		}
+		fmt.Printf("  Payload: spec-kit-agents v%s, spec-kit v%s\n", payload.TemplatesVersion, payload.SpecKitVersion)
+		fmt.Printf("  Payload hash: %s\n", payload.Hash)
+		return nil
 	},
 }
 
@@ -135,8 +216,10 @@ var updateCmd = &cobra.Command{
 This command:
   - Creates a backup of the current installation (unless --no-backup)
   - Updates to the version specified in the manifest
+  - Merges local modifications into the new version
   - Automatically rolls back on failure
   - Preserves installation history
+  - Removes old backups beyond the retention limits (default: keep 5)
 
 Examples:
   # Update to latest version with automatic backup
@@ -146,7 +229,16 @@ Examples:
   spec-kit-agents update --no-backup
 
   # Force update even if versions match
-  spec-kit-agents update --force`,
+  spec-kit-agents update --force
+
+  # Stop instead of writing .new/.orig files when local changes conflict
+  spec-kit-agents update --on-conflict=abort
+
+  # Keep the backups of the last 30 days, at most 1 GB
+  spec-kit-agents update --keep-backups 0 --keep-backups-within 720h --max-backup-size 1GB
+
+  # Update the global installation
+  spec-kit-agents update --global`,
 	RunE: runUpdate,
 }
 
@@ -171,6 +263,220 @@ Examples:
 	RunE: runRollback,
 }
 
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove an installation",
+	Long: `Remove spec-kit-agents from an installation prefix.
+
+This command removes:
+  - The spec-kit files install copied to .specify/
+  - The version lock file
+  - The cat-* agents in ~/.claude/agents/
+  - The speckit.* commands in ~/.claude/commands/
+
+Specs and memory you added to .specify/ and other files in ~/.claude are
+left untouched.
+
+Examples:
+  # Show what would be removed
+  spec-kit-agents uninstall --dry-run
+
+  # Uninstall, keeping a backup that can be restored with rollback
+  spec-kit-agents uninstall --backup
+
+  # Uninstall without confirmation
+  spec-kit-agents uninstall --force`,
+	RunE: runUninstall,
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the installation",
+	Long: `Verify that the installation is complete and intact.
+
+By default this checks that the installed directories, version manifest,
+version lock and Claude Code files are in place. With --deep, every
+installed file is also compared against the hash recorded in the version
+lock, and modified, missing and extra files are reported.
+
+Exits with status 6 if drift is detected and 1 if verification fails.
+
+Examples:
+  # Quick structural check
+  spec-kit-agents verify
+
+  # Compare every installed file against the version lock
+  spec-kit-agents verify --deep`,
+	RunE: runVerify,
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show installed files that differ from the version lock",
+	Long: `Compare every installed file against the hash recorded in the version
+lock and report modified, missing and extra files. Extra files are looked
+for in .specify/templates, .specify/scripts and the cat-* agents and
+speckit.* commands in ~/.claude; your own specs and memory are not drift.
+
+This is the same check as 'verify --deep'. Run it before 'update --force'
+to find hand-edited agents and spec-kit files that would be overwritten.
+
+Exits with status 6 if drift is detected.`,
+	RunE: runDiff,
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the install log",
+	Long: `Show the install log of an installation.
+
+install, update, rollback and uninstall append everything they do, including
+debug messages, to the install log in the installation directory
+(` + config.InstallLogFile + `), or to the file given with --log-file. The log
+is rotated when it grows past 1 MiB, keeping 3 older generations; this command
+reads them as well. The log is kept when the installation is uninstalled.
+
+Examples:
+  # Show the last 50 entries
+  spec-kit-agents logs
+
+  # Show every entry logged by update
+  spec-kit-agents logs --component update --lines 0
+
+  # Keep printing entries as they are written
+  spec-kit-agents logs --follow`,
+	RunE: runLogs,
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Manage installation backups",
+	Long: `Create, verify, prune, export and import installation backups. update
+and uninstall --backup also make backups.
+
+Backups are kept in a content-addressed store next to the installation
+(<prefix>.backups), where each file is stored once, compressed. Each backup is
+a manifest that records the installed versions, the command that made it and
+the hash of every file it holds. List backups with 'rollback --list'.`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Back up the installation",
+	Long: `Back up the installation and the Claude Code files it owns, as update does
+before changing anything. Restore the backup with 'rollback --backup-id'.
+
+The backup is pinned: the retention limits applied after an update and by
+'backup prune' do not remove it. 'backup prune --include-pinned' does.
+
+Examples:
+  spec-kit-agents backup create --label "before experiment"`,
+	Args: cobra.NoArgs,
+	RunE: runBackupCreate,
+}
+
+var backupExportCmd = &cobra.Command{
+	Use:   "export <backup-id> <file.tar.gz>",
+	Short: "Write a backup to an archive",
+	Long: `Write a backup to a .tar.gz archive that 'backup import' can add to an
+installation on another machine, or that can be attached to a bug report.
+The backup is verified first and a corrupt backup is not exported.
+
+Examples:
+  spec-kit-agents backup export backup-20251022-120000 known-good.tar.gz`,
+	Args: cobra.ExactArgs(2),
+	RunE: runBackupExport,
+}
+
+var backupImportCmd = &cobra.Command{
+	Use:   "import <file.tar.gz>",
+	Short: "Add an exported backup to the installation's backups",
+	Long: `Add a backup written by 'backup export' to the backups of the installation,
+which does not need to be installed yet. The archive is checked against the
+hashes recorded when it was exported, and the paths in its version lock are
+moved to this installation and ~/.claude. Restore it with 'rollback --backup-id'.
+
+Examples:
+  spec-kit-agents backup import known-good.tar.gz
+  spec-kit-agents rollback --backup-id backup-20251022-120000`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupImport,
+}
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify <backup-id>",
+	Short: "Check that a backup is intact",
+	Long: `Compare every file of a backup against the hashes recorded in its
+manifest and report modified and missing files.
+
+rollback runs the same check and refuses a backup that fails it. Backup
+directories made by older releases are checked against their backup.json,
+if they have one, and extra files are reported as well.
+
+Exits with status 12 if the backup is corrupt and 7 if it does not exist.
+
+Examples:
+  spec-kit-agents backup verify backup-20251022-120000`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupVerify,
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old backups",
+	Long: `Remove the backups that the retention limits do not keep. update applies
+the same limits after every successful update.
+
+Backups are counted from the newest. A backup is removed if it is beyond
+--keep-backups, older than --keep-backups-within, or would take the total
+past --max-backup-size, which counts the space the backups take up in the
+store: files shared between backups count once, compressed. The newest
+backup is always kept. A limit of 0 does not apply.
+
+Backups made with 'backup create' and backups with a label are pinned: the
+limits neither count nor remove them unless --include-pinned is given.
+
+Examples:
+  # Show what would be removed with the default limits (keep 5)
+  spec-kit-agents backup prune --dry-run
+
+  # Keep only the backups of the last week
+  spec-kit-agents backup prune --keep-backups 0 --keep-backups-within 168h`,
+	Args: cobra.NoArgs,
+	RunE: runBackupPrune,
+}
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Version manifest utilities",
+	Long:  "Utilities for maintaining the version manifest (.specify/version-manifest.json).",
+}
+
+var manifestHashCmd = &cobra.Command{
+	Use:   "hash [directory|archive]",
+	Short: "Compute the integrity hash of a spec-kit directory or archive",
+	Long: `Compute the deterministic tree hash of a spec-kit directory in the format
+used by the manifest "integrity" field (sha256-[64 hex chars]).
+
+The version manifest itself and any .git directories are excluded from the
+hash. Paste the printed value into .specify/version-manifest.json whenever
+the vendored spec-kit files change.
+
+For a file (an "archive" source), the hash of the file itself is printed.
+
+Examples:
+  # Hash the vendored .specify/ directory
+  spec-kit-agents manifest hash
+
+  # Hash another directory
+  spec-kit-agents manifest hash path/to/spec-kit
+
+  # Hash a release archive
+  spec-kit-agents manifest hash spec-kit-0.0.72.tar.gz`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runManifestHash,
+}
+
 func init() {
 	// Add subcommands
 	rootCmd.AddCommand(installCmd)
@@ -179,34 +485,158 @@ func init() {
 	rootCmd.AddCommand(checkCmd)
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupVerifyCmd)
+	backupCmd.AddCommand(backupExportCmd)
+	backupCmd.AddCommand(backupImportCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	rootCmd.AddCommand(manifestCmd)
+	manifestCmd.AddCommand(manifestHashCmd)
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err}
+	})
 
 	// Global flags
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress non-error output")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format (text, json, yaml); json and yaml write logs to stderr")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "Log format for the console and log file (text, json; default: $"+config.LogFormatEnvVar+" or text)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Append log messages to this file (default for install, update, rollback and uninstall: the install log in the installation directory)")
 
 	// Install command flags
 	installCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (auto-detected if not specified)")
-	installCmd.Flags().BoolVar(&installGlobal, "global", false, "Install globally to ~/.claude/agents/")
+	installCmd.Flags().BoolVar(&installGlobal, "global", false, "Install globally to ~/spec-kit-agents")
 	installCmd.Flags().BoolVar(&installForce, "force", false, "Force installation even if already installed")
 	installCmd.Flags().BoolVar(&installDryRun, "dry-run", false, "Show what would be done without actually installing")
+	installCmd.Flags().BoolVar(&skipIntegrity, "skip-integrity", false, "Skip spec-kit integrity verification")
+	installCmd.Flags().StringVar(&sourceDir, "source", "", "Source tree to install from (default: auto-detect, or $"+install.SourceEnvVar+")")
+	installCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
 
 	// Status command flags
 	statusCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix to check (default: auto-detect)")
+	statusCmd.Flags().BoolVar(&installGlobal, "global", false, "Show status of the global installation")
+
+	// Check command flags
+	checkCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix to check (default: auto-detect)")
+	checkCmd.Flags().BoolVar(&installGlobal, "global", false, "Check the global installation")
+	checkCmd.Flags().StringVar(&sourceDir, "source", "", "Source tree to check for updates (default: auto-detect, or $"+install.SourceEnvVar+")")
 
 	// Update command flags
 	updateCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	updateCmd.Flags().BoolVar(&installGlobal, "global", false, "Update the global installation")
 	updateCmd.Flags().BoolVar(&updateNoBackup, "no-backup", false, "Skip backup creation before update")
 	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Force update even if versions match")
 	updateCmd.Flags().BoolVar(&updateSkipVerify, "skip-verify", false, "Skip version compatibility verification")
+	updateCmd.Flags().BoolVar(&skipIntegrity, "skip-integrity", false, "Skip spec-kit integrity verification")
+	updateCmd.Flags().StringVar(&updateOnConflict, "on-conflict", "sidecar", "How to handle conflicting local changes (sidecar, markers, abort)")
+	updateCmd.Flags().StringVar(&sourceDir, "source", "", "Source tree to update from (default: auto-detect, or $"+install.SourceEnvVar+")")
+	updateCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+	addRetentionFlags(updateCmd)
 
 	// Rollback command flags
 	rollbackCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	rollbackCmd.Flags().BoolVar(&installGlobal, "global", false, "Rollback the global installation")
 	rollbackCmd.Flags().StringVar(&rollbackBackupID, "backup-id", "", "Specific backup to restore (default: latest)")
 	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "List available backups")
 	rollbackCmd.Flags().BoolVar(&rollbackForce, "force", false, "Force rollback without confirmation")
+	rollbackCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+
+	// Uninstall command flags
+	uninstallCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	uninstallCmd.Flags().BoolVar(&installGlobal, "global", false, "Uninstall the global installation")
+	uninstallCmd.Flags().BoolVar(&uninstallDryRun, "dry-run", false, "Show what would be removed without removing anything")
+	uninstallCmd.Flags().BoolVar(&uninstallBackup, "backup", false, "Keep a backup that can be restored with rollback")
+	uninstallCmd.Flags().BoolVar(&uninstallForce, "force", false, "Uninstall without confirmation")
+	uninstallCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+
+	// Verify command flags
+	verifyCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	verifyCmd.Flags().BoolVar(&installGlobal, "global", false, "Verify the global installation")
+	verifyCmd.Flags().BoolVar(&verifyDeep, "deep", false, "Compare every installed file against the version lock")
+
+	// Diff command flags
+	diffCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	diffCmd.Flags().BoolVar(&installGlobal, "global", false, "Diff the global installation")
+
+	// Logs command flags
+	logsCmd.Flags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	logsCmd.Flags().BoolVar(&installGlobal, "global", false, "Show the log of the global installation")
+	logsCmd.Flags().StringVar(&logsComponent, "component", "", "Only show entries of this component (e.g. installer, update, backup)")
+	logsCmd.Flags().StringVar(&logsOperation, "operation", "", "Only show entries of this operation ID (see the version lock history)")
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "Number of entries to show (0 for all)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing entries as they are written")
+
+	// Backup command flags
+	backupCmd.PersistentFlags().StringVar(&installPrefix, "prefix", "", "Installation prefix (default: auto-detect)")
+	backupCmd.PersistentFlags().BoolVar(&installGlobal, "global", false, "Use the backups of the global installation")
+	backupCreateCmd.Flags().StringVar(&backupLabel, "label", "", "Description shown by rollback --list")
+	backupCreateCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+	backupExportCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+	backupImportCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+	backupPruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show which backups would be removed without removing them")
+	backupPruneCmd.Flags().BoolVar(&pruneIncludePinned, "include-pinned", false, "Also remove backups made with backup create or given a label")
+	backupPruneCmd.Flags().DurationVar(&lockWait, "wait", 0, "Wait up to this long for another operation on the installation to finish (e.g. 30s)")
+	addRetentionFlags(backupPruneCmd)
+}
+
+// addRetentionFlags adds the backup retention limits to a command
+func addRetentionFlags(cmd *cobra.Command) {
+	defaults := install.DefaultRetentionPolicy
+	cmd.Flags().IntVar(&backupKeepLast, "keep-backups", defaults.KeepLast, "Keep at most this many backups (0 for no limit)")
+	cmd.Flags().DurationVar(&backupKeepWithin, "keep-backups-within", defaults.KeepWithin, "Remove backups older than this, e.g. 720h (0 for no limit)")
+	cmd.Flags().StringVar(&backupMaxSize, "max-backup-size", "0", "Remove the oldest backups until the rest fit in this size, e.g. 1GB (0 for no limit)")
+}
+
+// retentionPolicy returns the backup retention limits given on the command line
+func retentionPolicy() (install.RetentionPolicy, error) {
+	if backupKeepLast < 0 || backupKeepWithin < 0 {
+		return install.RetentionPolicy{}, &usageError{fmt.Errorf("backup retention limits must not be negative")}
+	}
+	maxSize, err := install.ParseSize(backupMaxSize)
+	if err != nil {
+		return install.RetentionPolicy{}, &usageError{fmt.Errorf("invalid --max-backup-size: %w", err)}
+	}
+	return install.RetentionPolicy{
+		KeepLast:   backupKeepLast,
+		KeepWithin: backupKeepWithin,
+		MaxSize:    maxSize,
+	}, nil
 }
 
+// createLogger creates the logger for a command, also writing to --log-file
+// if given
 func createLogger() (*config.Logger, error) {
+	return newLogger(logFile)
+}
+
+// createInstallLogger creates the logger for a command that changes the
+// installation at prefix. Besides the console it appends to --log-file or by
+// default to the installation's install log, which is not used for dry runs
+// or a prefix that does not exist so that neither creates the directory.
+func createInstallLogger(prefix string, dryRun bool) (*config.Logger, error) {
+	path := logFile
+	if path == "" && !dryRun && config.PathExists(prefix) {
+		var err error
+		if path, err = config.GetInstallLogPath(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	logger, err := newLogger(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("cli", "spec-kit-agents %s: %s", Version, strings.Join(os.Args[1:], " "))
+	return logger, nil
+}
+
+func newLogger(logFilePath string) (*config.Logger, error) {
 	// Determine log level
 	logLevel := config.INFO
 	if verbose {
@@ -217,16 +647,45 @@ func createLogger() (*config.Logger, error) {
 	}
 
 	// Create logger
-	logger, err := config.NewLogger(logLevel, "", !quiet) // Log to console only for now
+	logger, err := config.NewLogger(logLevel, logFilePath, !quiet)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create logger: %w", err)
 	}
 
+	// Keep stdout for the result document
+	if structuredOutput() {
+		logger.SetOutput(os.Stderr)
+	}
+
+	if logFormat != config.LogFormatText {
+		formatter, err := config.ParseLogFormat(logFormat)
+		if err != nil {
+			return nil, err
+		}
+		logger.SetFormatter(formatter)
+	}
+
+	// Lets a pipeline correlate these logs and history entries with its own
+	if id := os.Getenv(config.OperationIDEnvVar); id != "" {
+		logger.SetOperationID(id)
+	}
+
 	return logger, nil
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
-	logger, err := createLogger()
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+	if !installDryRun {
+		// Created up front so that the install log can be opened in it
+		if err := config.EnsureDir(prefix); err != nil {
+			return err
+		}
+	}
+
+	logger, err := createInstallLogger(prefix, installDryRun)
 	if err != nil {
 		return err
 	}
@@ -234,16 +693,14 @@ func runInstall(cmd *cobra.Command, args []string) error {
 
 	// Prepare options
 	opts := install.Options{
-		Prefix: installPrefix,
-		Global: installGlobal,
-		Force:  installForce,
-		Quiet:  quiet,
-		DryRun: installDryRun,
-	}
-
-	// TODO: Handle global installation differently
-	if installGlobal {
-		return fmt.Errorf("global installation not yet implemented")
+		Prefix:        installPrefix,
+		Global:        installGlobal,
+		Force:         installForce,
+		Quiet:         quiet,
+		DryRun:        installDryRun,
+		SkipIntegrity: skipIntegrity,
+		Source:        sourceDir,
+		Wait:          lockWait,
 	}
 
 	// Run installation
@@ -257,6 +714,10 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("installation did not complete successfully")
 	}
 
+	if structuredOutput() {
+		return writeResult(os.Stdout, result)
+	}
+
 	return nil
 }
 
@@ -268,9 +729,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	defer logger.Close()
 
 	// Determine prefix
-	prefix := installPrefix
-	if prefix == "" {
-		prefix = config.DetermineInstallPrefix()
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
 	}
 
 	// Get status
@@ -279,15 +740,25 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get installation status: %w", err)
 	}
 
+	if structuredOutput() {
+		return writeResult(os.Stdout, status)
+	}
+
 	if !status.Installed {
 		fmt.Printf("No installation found at %s\n", prefix)
 		return nil
 	}
+	if status.Warning != "" {
+		logger.Warn("status", "%s", status.Warning)
+	}
 
 	// Display status
 	fmt.Printf("Installation Status\n")
 	fmt.Printf("===================\n\n")
 	fmt.Printf("  Location:              %s\n", status.Prefix)
+	if status.Global {
+		fmt.Printf("  Scope:                 global\n")
+	}
 	fmt.Printf("  Installation ID:       %s\n", status.InstallationID)
 	fmt.Printf("  Installed at:          %s\n", status.InstalledAt)
 	fmt.Printf("  Last verified:         %s\n", status.LastVerified)
@@ -312,9 +783,9 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	defer logger.Close()
 
 	// Determine prefix
-	prefix := installPrefix
-	if prefix == "" {
-		prefix = config.DetermineInstallPrefix()
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
 	}
 
 	logger.Info("checker", "Checking version compatibility...")
@@ -345,13 +816,16 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	}
 
 	if !config.PathExists(paths.VersionLock) {
-		return fmt.Errorf("no installation found at %s", prefix)
+		return fmt.Errorf("%w at %s", install.ErrNotInstalled, prefix)
 	}
 
 	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
 	if err != nil {
 		return fmt.Errorf("failed to load version lock: %w", err)
 	}
+	if lock.Recovered != nil {
+		logger.Warn("checker", "%v", lock.Recovered)
+	}
 
 	installedVersion, err := version.GetInstalledSpecKitVersion(lock)
 	if err != nil {
@@ -360,6 +834,25 @@ func runCheck(cmd *cobra.Command, args []string) error {
 
 	logger.Info("checker", "Installed spec-kit version: v%s", installedVersion)
 
+	output := CheckOutput{
+		Prefix:     paths.Prefix,
+		MinVersion: compatibility.MinVersion,
+		MaxVersion: compatibility.MaxVersion,
+	}
+
+	// Report whether the source manifest offers a newer version
+	if available, message, err := install.CheckForUpdates(prefix, sourceDir, logger); err != nil {
+		logger.Debug("checker", "Could not check for updates: %v", err)
+	} else {
+		output.UpdateAvailable = available
+		output.UpdateMessage = message
+		if available {
+			logger.Info("checker", "%s (run 'spec-kit-agents update')", message)
+		} else {
+			logger.Info("checker", "%s", message)
+		}
+	}
+
 	// Check compatibility
 	result, err := version.CheckCompatibility(
 		installedVersion,
@@ -371,6 +864,17 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("compatibility check failed: %w", err)
 	}
+	output.Compatibility = result
+
+	if structuredOutput() {
+		if err := writeResult(os.Stdout, output); err != nil {
+			return err
+		}
+		if !result.IsCompatible() {
+			return &reportedError{fmt.Errorf("version compatibility check failed: %w", version.ErrIncompatible)}
+		}
+		return nil
+	}
 
 	if result.IsCompatible() {
 		logger.Success("checker", "✓ Versions are compatible")
@@ -383,27 +887,42 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	// Incompatible
 	logger.Error("checker", "✗ Version incompatibility detected")
 	fmt.Println(result.GetIssuesText())
-	return fmt.Errorf("version compatibility check failed")
+	return fmt.Errorf("version compatibility check failed: %w", version.ErrIncompatible)
 }
 
 func runUpdate(cmd *cobra.Command, args []string) error {
-	logger, err := createLogger()
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	logger, err := createInstallLogger(prefix, false)
 	if err != nil {
 		return err
 	}
 	defer logger.Close()
 
-	// Determine prefix
-	prefix := installPrefix
-	if prefix == "" {
-		prefix = config.DetermineInstallPrefix()
+	onConflict, err := install.ParseConflictStrategy(updateOnConflict)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	retention, err := retentionPolicy()
+	if err != nil {
+		return err
 	}
 
 	// Prepare options
 	opts := install.UpdateOptions{
-		Backup:     !updateNoBackup,
-		Force:      updateForce,
-		SkipVerify: updateSkipVerify,
+		Backup:        !updateNoBackup,
+		Force:         updateForce,
+		SkipVerify:    updateSkipVerify,
+		SkipIntegrity: skipIntegrity,
+		OnConflict:    onConflict,
+		Source:        sourceDir,
+		Wait:          lockWait,
+		Retention:     retention,
 	}
 
 	// Run update
@@ -417,6 +936,10 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("update did not complete successfully")
 	}
 
+	if structuredOutput() {
+		return writeResult(os.Stdout, result)
+	}
+
 	// Display summary
 	fmt.Println()
 	fmt.Println("Update Summary")
@@ -427,8 +950,29 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	if result.BackupCreated {
 		fmt.Printf("  Backup ID:    %s\n", result.BackupID)
 	}
+	if len(result.BackupsRemoved) > 0 {
+		fmt.Printf("  Old backups:  %d removed\n", len(result.BackupsRemoved))
+	}
 	fmt.Println()
 
+	if changes := result.LocalChanges; changes != nil {
+		fmt.Println("Local Modifications:")
+		for _, path := range changes.Preserved {
+			fmt.Printf("  kept:     %s\n", path)
+		}
+		for _, path := range changes.Merged {
+			fmt.Printf("  merged:   %s\n", path)
+		}
+		for _, path := range changes.Conflicts {
+			fmt.Printf("  conflict: %s\n", path)
+		}
+		if len(changes.Conflicts) > 0 && onConflict == install.ConflictSidecar {
+			fmt.Printf("  Compare each conflicting file with its %s (new version) and %s (previous version)\n",
+				install.SidecarNewSuffix, install.SidecarOrigSuffix)
+		}
+		fmt.Println()
+	}
+
 	if len(result.Warnings) > 0 {
 		fmt.Println("Warnings:")
 		for _, warning := range result.Warnings {
@@ -441,17 +985,17 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 }
 
 func runRollback(cmd *cobra.Command, args []string) error {
-	logger, err := createLogger()
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
 	if err != nil {
-		return err
+		return &usageError{err}
 	}
-	defer logger.Close()
 
-	// Determine prefix
-	prefix := installPrefix
-	if prefix == "" {
-		prefix = config.DetermineInstallPrefix()
+	logger, err := createInstallLogger(prefix, rollbackList)
+	if err != nil {
+		return err
 	}
+	defer logger.Close()
 
 	// List backups if requested
 	if rollbackList {
@@ -460,6 +1004,10 @@ func runRollback(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to list backups: %w", err)
 		}
 
+		if structuredOutput() {
+			return writeResult(os.Stdout, backups)
+		}
+
 		if len(backups) == 0 {
 			fmt.Println("No backups found")
 			return nil
@@ -468,7 +1016,19 @@ func runRollback(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Available backups for %s:\n\n", prefix)
 		for i, backup := range backups {
 			fmt.Printf("%d. %s\n", i+1, backup.BackupID)
+			if backup.Label != "" {
+				fmt.Printf("   Label:   %s\n", backup.Label)
+			}
 			fmt.Printf("   Created: %s\n", backup.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+			if backup.Trigger != "" {
+				fmt.Printf("   Trigger: %s\n", backup.Trigger)
+			}
+			if v, ok := backup.Versions["spec-kit-agents"]; ok {
+				fmt.Printf("   Version: v%s\n", v)
+			}
+			if backup.Size > 0 {
+				fmt.Printf("   Size:    %s\n", install.FormatSize(backup.Size))
+			}
 			fmt.Printf("   Path:    %s\n", backup.BackupPath)
 			fmt.Println()
 		}
@@ -482,11 +1042,14 @@ func runRollback(cmd *cobra.Command, args []string) error {
 	}
 
 	if !canRollback {
-		return fmt.Errorf("cannot rollback: %s", message)
+		return fmt.Errorf("cannot rollback: %w (%s)", install.ErrBackupNotFound, message)
 	}
 
 	// Confirm rollback if not forced
 	if !rollbackForce && !quiet {
+		if structuredOutput() {
+			return errConfirmationRequired
+		}
 		fmt.Printf("This will rollback your installation.\n")
 		fmt.Printf("%s\n\n", message)
 		fmt.Print("Are you sure you want to continue? (yes/no): ")
@@ -504,6 +1067,7 @@ func runRollback(cmd *cobra.Command, args []string) error {
 	opts := install.RollbackOptions{
 		BackupID: rollbackBackupID,
 		Force:    rollbackForce,
+		Wait:     lockWait,
 	}
 
 	// Run rollback
@@ -517,6 +1081,10 @@ func runRollback(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("rollback did not complete successfully")
 	}
 
+	if structuredOutput() {
+		return writeResult(os.Stdout, result)
+	}
+
 	// Display summary
 	fmt.Println()
 	fmt.Println("Rollback Summary")
@@ -529,3 +1097,472 @@ func runRollback(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runUninstall(cmd *cobra.Command, args []string) error {
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	logger, err := createInstallLogger(prefix, uninstallDryRun)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	// Confirm uninstall if not forced
+	if !uninstallDryRun && !uninstallForce && !quiet {
+		if structuredOutput() {
+			return errConfirmationRequired
+		}
+		fmt.Printf("This will remove the installation at %s.\n", prefix)
+		if !uninstallBackup {
+			fmt.Printf("No backup will be kept (use --backup to keep one).\n")
+		}
+		fmt.Print("\nAre you sure you want to continue? (yes/no): ")
+
+		var response string
+		fmt.Scanln(&response)
+
+		if response != "yes" && response != "y" {
+			fmt.Println("Uninstall cancelled")
+			return nil
+		}
+	}
+
+	// Prepare options
+	opts := install.UninstallOptions{
+		DryRun: uninstallDryRun,
+		Backup: uninstallBackup,
+		Wait:   lockWait,
+	}
+
+	// Run uninstall
+	result, err := install.Uninstall(prefix, opts, logger)
+	if err != nil {
+		logger.Error("uninstall", "Uninstall failed: %v", err)
+		return err
+	}
+
+	if !result.Success {
+		return fmt.Errorf("uninstall did not complete successfully")
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, result)
+	}
+
+	if uninstallDryRun {
+		return nil
+	}
+
+	// Display summary
+	fmt.Println()
+	fmt.Println("Uninstall Summary")
+	fmt.Println("=================")
+	fmt.Printf("  Location:  %s\n", result.Prefix)
+	fmt.Printf("  Removed:   %d path(s)\n", len(result.RemovedPaths))
+	if len(result.KeptPaths) > 0 {
+		fmt.Printf("  Kept:      %d modified or user file(s)\n", len(result.KeptPaths))
+		for _, path := range result.KeptPaths {
+			fmt.Printf("    %s\n", path)
+		}
+	}
+	if result.BackupCreated {
+		fmt.Printf("  Backup ID: %s\n", result.BackupID)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if verifyDeep {
+		return runDiff(cmd, args)
+	}
+
+	logger, err := createLogger()
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	paths, err := install.GetPaths(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	if !config.PathExists(paths.VersionLock) {
+		return fmt.Errorf("%w at %s", install.ErrNotInstalled, prefix)
+	}
+
+	logger.Info("verify", "Verifying installation at %s...", paths.Prefix)
+	if err := install.VerifyInstallation(paths); err != nil {
+		logger.Error("verify", "✗ Verification failed")
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if err := install.RecordVerification(paths, logger); err != nil {
+		logger.Warn("verify", "Failed to record verification time: %v", err)
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, VerifyOutput{Prefix: paths.Prefix, Verified: true})
+	}
+
+	logger.Success("verify", "✓ Installation verified")
+	return nil
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	logger, err := createLogger()
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	paths, err := install.GetPaths(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	logger.Info("verify", "Comparing installed files with version lock...")
+	report, err := install.VerifyInstallationDeep(paths)
+	if err != nil {
+		return fmt.Errorf("deep verification failed: %w", err)
+	}
+
+	if structuredOutput() {
+		if !report.HasDrift() {
+			if err := install.RecordVerification(paths, logger); err != nil {
+				logger.Warn("verify", "Failed to record verification time: %v", err)
+			}
+		}
+		if err := writeResult(os.Stdout, report); err != nil {
+			return err
+		}
+		if err := report.Err(); err != nil {
+			return &reportedError{err}
+		}
+		return nil
+	}
+
+	if !report.HasDrift() {
+		if err := install.RecordVerification(paths, logger); err != nil {
+			logger.Warn("verify", "Failed to record verification time: %v", err)
+		}
+		logger.Success("verify", "✓ %s", report.GetSummary())
+		return nil
+	}
+
+	// Display drift
+	fmt.Println()
+	fmt.Println("Drift Report")
+	fmt.Println("============")
+	fmt.Printf("  Location: %s\n\n", report.Prefix)
+	for _, path := range report.Modified {
+		fmt.Printf("  modified: %s\n", path)
+	}
+	for _, path := range report.Missing {
+		fmt.Printf("  missing:  %s\n", path)
+	}
+	for _, path := range report.Extra {
+		fmt.Printf("  extra:    %s\n", path)
+	}
+	fmt.Println()
+
+	logger.Error("verify", "✗ %s", report.GetSummary())
+	return report.Err()
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	if logsFollow && structuredOutput() {
+		return &usageError{fmt.Errorf("--follow cannot be used with --output %s", outputFormat)}
+	}
+	if logsLines < 0 {
+		return &usageError{fmt.Errorf("--lines must not be negative")}
+	}
+
+	path := logFile
+	if path == "" {
+		prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+		if err != nil {
+			return &usageError{err}
+		}
+		if path, err = config.GetInstallLogPath(prefix); err != nil {
+			return err
+		}
+	}
+
+	filter := config.LogFilter{Component: logsComponent, OperationID: logsOperation}
+	entries, err := config.ReadLogEntries(path, filter, logsLines)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		output := LogsOutput{Path: path, Entries: entries}
+		if output.Entries == nil {
+			output.Entries = []config.LogEntry{}
+		}
+		return writeResult(os.Stdout, output)
+	}
+
+	for _, entry := range entries {
+		fmt.Println(entry)
+	}
+
+	if logsFollow {
+		return config.FollowLogFile(path, filter, func(entry config.LogEntry) {
+			fmt.Println(entry)
+		})
+	}
+	return nil
+}
+
+func runBackupCreate(cmd *cobra.Command, args []string) error {
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	logger, err := createInstallLogger(prefix, false)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	opts := install.BackupOptions{
+		Trigger: install.ManualBackupTrigger,
+		Label:   backupLabel,
+		Wait:    lockWait,
+	}
+	backup, err := install.BackupInstallation(prefix, opts, logger)
+	if err != nil {
+		logger.Error("backup", "Backup failed: %v", err)
+		return err
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, backup)
+	}
+
+	fmt.Println()
+	fmt.Printf("  Backup ID:  %s\n", backup.BackupID)
+	fmt.Printf("  To restore: spec-kit-agents rollback --backup-id=%s\n", backup.BackupID)
+	fmt.Println()
+	return nil
+}
+
+func runBackupExport(cmd *cobra.Command, args []string) error {
+	logger, err := createLogger()
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	backup, err := install.FindBackup(prefix, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := install.ExportBackup(backup, args[1], install.ExportOptions{Wait: lockWait}, logger); err != nil {
+		logger.Error("backup", "Export failed: %v", err)
+		return err
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, ExportOutput{BackupID: backup.BackupID, Path: args[1]})
+	}
+	return nil
+}
+
+func runBackupImport(cmd *cobra.Command, args []string) error {
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	logger, err := createInstallLogger(prefix, false)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	backup, err := install.ImportBackup(prefix, args[0], install.ImportOptions{Wait: lockWait}, logger)
+	if err != nil {
+		logger.Error("backup", "Import failed: %v", err)
+		return err
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, backup)
+	}
+
+	fmt.Println()
+	fmt.Printf("  Backup ID:  %s\n", backup.BackupID)
+	if backup.Label != "" {
+		fmt.Printf("  Label:      %s\n", backup.Label)
+	}
+	fmt.Printf("  To restore: spec-kit-agents rollback --backup-id=%s\n", backup.BackupID)
+	fmt.Println()
+	return nil
+}
+
+func runBackupVerify(cmd *cobra.Command, args []string) error {
+	logger, err := createLogger()
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	backup, err := install.FindBackup(prefix, args[0])
+	if err != nil {
+		return err
+	}
+
+	logger.Info("backup", "Verifying backup %s...", backup.BackupID)
+	verification, err := install.VerifyBackup(backup)
+	if err != nil {
+		return fmt.Errorf("cannot verify backup %s: %w", backup.BackupID, err)
+	}
+
+	if structuredOutput() {
+		if err := writeResult(os.Stdout, verification); err != nil {
+			return err
+		}
+		if err := verification.Err(); err != nil {
+			return &reportedError{err}
+		}
+		return nil
+	}
+
+	if verification.Err() == nil {
+		logger.Success("backup", "✓ Backup %s verified (%d files)", backup.BackupID, verification.Checked)
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println("Backup Verification")
+	fmt.Println("===================")
+	fmt.Printf("  Backup: %s\n\n", backup.BackupPath)
+	for _, path := range verification.Modified {
+		fmt.Printf("  modified: %s\n", path)
+	}
+	for _, path := range verification.Missing {
+		fmt.Printf("  missing:  %s\n", path)
+	}
+	for _, path := range verification.Extra {
+		fmt.Printf("  extra:    %s\n", path)
+	}
+	fmt.Println()
+
+	return verification.Err()
+}
+
+func runBackupPrune(cmd *cobra.Command, args []string) error {
+	policy, err := retentionPolicy()
+	if err != nil {
+		return err
+	}
+
+	// Determine prefix
+	prefix, err := install.ResolvePrefix(installPrefix, installGlobal)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	logger, err := createInstallLogger(prefix, pruneDryRun)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	opts := install.PruneOptions{
+		Policy:        policy,
+		IncludePinned: pruneIncludePinned,
+		DryRun:        pruneDryRun,
+		Wait:          lockWait,
+	}
+	result, err := install.PruneBackups(prefix, opts, logger)
+	if err != nil {
+		logger.Error("backup", "Prune failed: %v", err)
+		return err
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, result)
+	}
+
+	if len(result.Removed) == 0 {
+		fmt.Printf("No backups to remove (%d kept)\n", len(result.Kept))
+		return nil
+	}
+
+	action := "Removed"
+	if result.DryRun {
+		action = "Would remove"
+	}
+	fmt.Println()
+	for _, backup := range result.Removed {
+		fmt.Printf("  %s %s (created %s, %s)\n", action, backup.BackupID,
+			backup.CreatedAt.Format("2006-01-02 15:04:05 UTC"), install.FormatSize(backup.Size))
+	}
+	fmt.Println()
+	fmt.Printf("%s %d backup(s), %s; %d kept\n", action, len(result.Removed),
+		install.FormatSize(result.FreedBytes), len(result.Kept))
+
+	return nil
+}
+
+func runManifestHash(cmd *cobra.Command, args []string) error {
+	target := ".specify"
+	if len(args) > 0 {
+		target = args[0]
+	}
+
+	var hash string
+	var err error
+	if info, statErr := os.Stat(target); statErr == nil && info.Mode().IsRegular() {
+		hash, err = version.ComputeFileHash(target)
+	} else {
+		hash, err = version.ComputeTreeHash(target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to compute integrity hash: %w", err)
+	}
+
+	if structuredOutput() {
+		return writeResult(os.Stdout, HashOutput{Path: target, Integrity: hash})
+	}
+
+	fmt.Println(hash)
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/install"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// ErrorOutput is the document written instead of a result when a command
+// fails in json or yaml output mode
+type ErrorOutput struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes a failed command
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// VersionOutput is the result of the version command
+type VersionOutput struct {
+	Version   string               `json:"version"`
+	BuildTime string               `json:"build_time"`
+	GitCommit string               `json:"git_commit"`
+	Payload   *install.PayloadInfo `json:"payload,omitempty"`
+}
+
+// CheckOutput is the result of the check command
+type CheckOutput struct {
+	Prefix          string                       `json:"prefix"`
+	MinVersion      string                       `json:"min_version"`
+	MaxVersion      string                       `json:"max_version"`
+	Compatibility   *version.CompatibilityResult `json:"compatibility"`
+	UpdateAvailable bool                         `json:"update_available"`
+	UpdateMessage   string                       `json:"update_message,omitempty"`
+}
+
+// VerifyOutput is the result of the verify command without --deep
+type VerifyOutput struct {
+	Prefix   string `json:"prefix"`
+	Verified bool   `json:"verified"`
+}
+
+// LogsOutput is the result of the logs command
+type LogsOutput struct {
+	Path    string            `json:"path"`
+	Entries []config.LogEntry `json:"entries"`
+}
+
+// ExportOutput is the result of the backup export command
+type ExportOutput struct {
+	BackupID string `json:"backup_id"`
+	Path     string `json:"path"`
+}
+
+// HashOutput is the result of the manifest hash command
+type HashOutput struct {
+	Path      string `json:"path"`
+	Integrity string `json:"integrity"`
+}
+
+// reportedError is returned by a command that has already written its result
+// and fails only to set the exit status
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string { return e.err.Error() }
+func (e *reportedError) Unwrap() error { return e.err }
+
+// validateOutputFormat checks the --output flag
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	default:
+		return &usageError{fmt.Errorf("invalid output format %q (must be text, json or yaml)", outputFormat)}
+	}
+}
+
+// structuredOutput reports whether results are written as json or yaml
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// writeResult writes a command result in the selected structured format
+func writeResult(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	if outputFormat == outputYAML {
+		// Converting from JSON keeps the field names and order identical
+		// in both formats
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		resetYAMLStyle(&node)
+
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		return encoder.Close()
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// resetYAMLStyle drops the JSON flow and quoting styles from a parsed
+// document so that it is written as block YAML
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// writeError writes a failed command as a structured error document
+func writeError(w io.Writer, err error) {
+	code, _ := classifyError(err)
+	output := ErrorOutput{Error: ErrorDetail{Code: code, Message: err.Error()}}
+	if writeErr := writeResult(w, output); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
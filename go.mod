@@ -3,9 +3,13 @@ module github.com/dkoenawan/claude-agent-templates
 go 1.25.3
 
 require (
-	github.com/Masterminds/semver/v3 v3.4.0 // indirect
-	github.com/google/uuid v1.6.0 // indirect
+	github.com/Masterminds/semver/v3 v3.4.0
+	github.com/google/uuid v1.6.0
+	github.com/spf13/cobra v1.10.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.10.1 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 )
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Environment variables read by the CLI to configure logging
+const (
+	// LogFormatEnvVar selects the log format when --log-format is not given
+	LogFormatEnvVar = "SPEC_KIT_AGENTS_LOG_FORMAT"
+	// OperationIDEnvVar sets the operation ID instead of generating one, so
+	// that a pipeline can correlate the tool's logs with its own
+	OperationIDEnvVar = "SPEC_KIT_AGENTS_OPERATION_ID"
+)
+
+// Log formats selected with --log-format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Formatter renders a log entry as a single line
+type Formatter interface {
+	Format(entry LogEntry) string
+}
+
+// ParseLogFormat returns the formatter for a log format name
+func ParseLogFormat(name string) (Formatter, error) {
+	switch name {
+	case LogFormatText:
+		return TextFormatter{}, nil
+	case LogFormatJSON:
+		return JSONFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (must be text or json)", name)
+	}
+}
+
+// operationPrefix marks the operation ID field of a text log line
+const operationPrefix = "op:"
+
+// TextFormatter writes "[timestamp] [LEVEL] [component] [op:ID] message"
+// lines, leaving out the operation ID field if there is none
+type TextFormatter struct{}
+
+// Format implements Formatter
+func (TextFormatter) Format(entry LogEntry) string {
+	line := fmt.Sprintf("[%s] [%s] [%s] ", entry.Time.UTC().Format(time.RFC3339), entry.Level, entry.Component)
+	if entry.OperationID != "" {
+		line += "[" + operationPrefix + entry.OperationID + "] "
+	}
+	return line + entry.Message
+}
+
+// JSONFormatter writes one JSON object per line with the fields of LogEntry,
+// including the operation ID
+type JSONFormatter struct{}
+
+// Format implements Formatter
+func (JSONFormatter) Format(entry LogEntry) string {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		// A LogEntry only holds strings and a time, so this cannot happen
+		return TextFormatter{}.Format(entry)
+	}
+	return string(data)
+}
+
+// consoleFormatter is the default console format, with emoji level markers
+// and without timestamps
+type consoleFormatter struct{}
+
+// Format implements Formatter
+func (consoleFormatter) Format(entry LogEntry) string {
+	return formatConsoleMessage(entry.Level, entry.Component, entry.Message)
+}
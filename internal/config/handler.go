@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Levels that Logger adds to the slog levels
+const (
+	// LevelSuccess reports a completed step; it ranks between INFO and WARN
+	LevelSuccess = slog.LevelInfo + 2
+	// LevelFatal reports an error the program exits on
+	LevelFatal = slog.LevelError + 4
+)
+
+// Attribute keys of the records a Logger emits
+const (
+	ComponentKey   = "component"
+	OperationIDKey = "operation_id"
+)
+
+// levelName returns the name a level is written with
+func levelName(level slog.Level) string {
+	switch level {
+	case LevelSuccess:
+		return "SUCCESS"
+	case LevelFatal:
+		return "FATAL"
+	default:
+		return level.String()
+	}
+}
+
+// formatHandler is a slog.Handler that writes records as LogEntry lines with
+// a Formatter. Records of ERROR and above go to errOut if it is set. Groups
+// are not supported; their attributes are treated as ungrouped.
+type formatHandler struct {
+	mu        *sync.Mutex // Shared with the handlers derived by WithAttrs
+	out       io.Writer
+	errOut    io.Writer
+	formatter Formatter
+	level     slog.Level
+	attrs     []slog.Attr
+}
+
+// Enabled implements slog.Handler
+func (h *formatHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+// Handle implements slog.Handler
+func (h *formatHandler) Handle(_ context.Context, record slog.Record) error {
+	entry := LogEntry{
+		Time:    record.Time.UTC(),
+		Level:   levelName(record.Level),
+		Message: record.Message,
+	}
+	setAttr := func(attr slog.Attr) bool {
+		switch attr.Key {
+		case ComponentKey:
+			entry.Component = attr.Value.String()
+		case OperationIDKey:
+			entry.OperationID = attr.Value.String()
+		}
+		return true
+	}
+	for _, attr := range h.attrs {
+		setAttr(attr)
+	}
+	record.Attrs(setAttr)
+
+	out := h.out
+	if record.Level >= slog.LevelError && h.errOut != nil {
+		out = h.errOut
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(out, h.formatter.Format(entry))
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (h *formatHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (h *formatHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// fanoutHandler passes records to every handler that is enabled for them
+type fanoutHandler []slog.Handler
+
+// Enabled implements slog.Handler
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle implements slog.Handler
+func (f fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// WithAttrs implements slog.Handler
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+// WithGroup implements slog.Handler
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
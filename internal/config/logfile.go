@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Log file rotation limits. A log file that has grown past MaxLogSize is
+// rotated when it is next opened, keeping MaxLogBackups older generations as
+// <file>.1 (newest) to <file>.N (oldest).
+const (
+	MaxLogSize    int64 = 1 << 20
+	MaxLogBackups       = 3
+)
+
+// LogEntry is a message read back from a log file
+type LogEntry struct {
+	Time        time.Time `json:"time"`
+	Level       string    `json:"level"`
+	Component   string    `json:"component"`
+	Message     string    `json:"message"`
+	OperationID string    `json:"operation_id,omitempty"`
+}
+
+// String formats the entry as a text log line
+func (e LogEntry) String() string {
+	return TextFormatter{}.Format(e)
+}
+
+// LogFilter selects log entries. Empty fields match every entry.
+type LogFilter struct {
+	Component   string
+	OperationID string
+}
+
+// Match reports whether an entry passes the filter
+func (f LogFilter) Match(entry LogEntry) bool {
+	return (f.Component == "" || entry.Component == f.Component) &&
+		(f.OperationID == "" || entry.OperationID == f.OperationID)
+}
+
+// rotatedLogPath returns the path of the nth older generation of a log file
+func rotatedLogPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateLogFile shifts a log file that has reached MaxLogSize to <file>.1,
+// dropping the oldest generation
+func rotateLogFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to check log file: %w", err)
+	}
+	if info.Size() < MaxLogSize {
+		return nil
+	}
+
+	if err := os.Remove(rotatedLogPath(path, MaxLogBackups)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	for n := MaxLogBackups - 1; n >= 1; n-- {
+		if err := os.Rename(rotatedLogPath(path, n), rotatedLogPath(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(path, rotatedLogPath(path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return nil
+}
+
+// LogFiles returns the existing generations of a log file, oldest first
+func LogFiles(path string) []string {
+	var files []string
+	for n := MaxLogBackups; n >= 1; n-- {
+		if PathExists(rotatedLogPath(path, n)) {
+			files = append(files, rotatedLogPath(path, n))
+		}
+	}
+	if PathExists(path) {
+		files = append(files, path)
+	}
+	return files
+}
+
+// ReadLogEntries reads the entries of a log file and its rotated generations,
+// oldest first. Only entries that match filter are returned, and only the last
+// limit of them unless limit is zero.
+func ReadLogEntries(path string, filter LogFilter, limit int) ([]LogEntry, error) {
+	files := LogFiles(path)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("log file not found: %s", path)
+	}
+
+	var entries []LogEntry
+	for _, file := range files {
+		fileEntries, err := readLogFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range fileEntries {
+			if filter.Match(entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// readLogFile parses one log file. Lines that do not start an entry, such as
+// the continuation of a multi-line message, are appended to the entry before.
+func readLogFile(path string) ([]LogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if entry, ok := ParseLogLine(line); ok {
+			entries = append(entries, entry)
+		} else if len(entries) > 0 {
+			entries[len(entries)-1].Message += "\n" + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ParseLogLine parses a line written by Logger to its log file in either
+// format
+func ParseLogLine(line string) (LogEntry, bool) {
+	if strings.HasPrefix(line, "{") {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Time.IsZero() {
+			return LogEntry{}, false
+		}
+		return entry, true
+	}
+
+	var fields [3]string
+	rest := line
+	for i := range fields {
+		if !strings.HasPrefix(rest, "[") {
+			return LogEntry{}, false
+		}
+		field, after, ok := strings.Cut(rest[1:], "] ")
+		if !ok {
+			return LogEntry{}, false
+		}
+		fields[i], rest = field, after
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return LogEntry{}, false
+	}
+
+	entry := LogEntry{Time: timestamp, Level: fields[1], Component: fields[2], Message: rest}
+	if strings.HasPrefix(rest, "["+operationPrefix) {
+		if id, message, ok := strings.Cut(rest[1+len(operationPrefix):], "] "); ok {
+			entry.OperationID, entry.Message = id, message
+		}
+	}
+	return entry, true
+}
+
+// followPollInterval is how often FollowLogFile checks for new entries
+const followPollInterval = 500 * time.Millisecond
+
+// FollowLogFile calls fn for every entry appended to a log file from now on
+// that matches filter. It starts reading the new file when the log is rotated
+// and only returns on error.
+func FollowLogFile(path string, filter LogFilter, fn func(LogEntry)) error {
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	var partial string
+	for {
+		time.Sleep(followPollInterval)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Rotated and not written to again yet
+				offset, partial = 0, ""
+				continue
+			}
+			return fmt.Errorf("failed to check log file: %w", err)
+		}
+		if info.Size() < offset {
+			// Rotated and written to again
+			offset, partial = 0, ""
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		data, err := readLogFrom(path, offset)
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+
+		// Keep an unfinished last line for the next round
+		lines := strings.Split(partial+string(data), "\n")
+		partial = lines[len(lines)-1]
+
+		var entries []LogEntry
+		for _, line := range lines[:len(lines)-1] {
+			if entry, ok := ParseLogLine(line); ok {
+				entries = append(entries, entry)
+			} else if len(entries) > 0 {
+				entries[len(entries)-1].Message += "\n" + line
+			}
+		}
+		for _, entry := range entries {
+			if filter.Match(entry) {
+				fn(entry)
+			}
+		}
+	}
+}
+
+// readLogFrom reads a log file from offset to its end
+func readLogFrom(path string, offset int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return data, nil
+}
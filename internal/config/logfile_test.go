@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger_RotatesLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), InstallLogFile)
+
+	// Fill the log past the limit once more than there are generations
+	for i := 0; i <= MaxLogBackups+1; i++ {
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", int(MaxLogSize))), 0644); err != nil {
+			t.Fatal(err)
+		}
+		logger, err := NewLogger(FATAL, path, false)
+		if err != nil {
+			t.Fatalf("NewLogger() error = %v", err)
+		}
+		logger.Info("test", "entry %d", i)
+		logger.Close()
+	}
+
+	if files := LogFiles(path); len(files) != MaxLogBackups+1 {
+		t.Errorf("LogFiles() = %v, want %d files", files, MaxLogBackups+1)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(data), "] entry 4\n") || strings.Contains(string(data), "entry 3") {
+		t.Errorf("log file = %q, want only the last entry", data)
+	}
+}
+
+func TestReadLogEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), InstallLogFile)
+	rotated := strings.Join([]string{
+		"[2025-01-01T10:00:00Z] [INFO] [installer] Installing",
+		"[2025-01-01T10:00:01Z] [ERROR] [update] Update failed: two",
+		"lines",
+	}, "\n") + "\n"
+	current := strings.Join([]string{
+		"[2025-01-02T10:00:00Z] [DEBUG] [update] Checking",
+		"[2025-01-02T10:00:01Z] [INFO] [backup] [op:op-1] Creating backup",
+		`{"time":"2025-01-02T10:00:02Z","level":"INFO","component":"update","message":"Done","operation_id":"op-1"}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(path+".1", []byte(rotated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(current), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter LogFilter
+		limit  int
+		want   []string
+	}{
+		{
+			name: "all entries across generations",
+			want: []string{"Installing", "Update failed: two\nlines", "Checking", "Creating backup", "Done"},
+		},
+		{
+			name:   "one component",
+			filter: LogFilter{Component: "update"},
+			want:   []string{"Update failed: two\nlines", "Checking", "Done"},
+		},
+		{
+			name:   "last entries of a component",
+			filter: LogFilter{Component: "update"},
+			limit:  2,
+			want:   []string{"Checking", "Done"},
+		},
+		{
+			name:   "one operation",
+			filter: LogFilter{OperationID: "op-1"},
+			want:   []string{"Creating backup", "Done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadLogEntries(path, tt.filter, tt.limit)
+			if err != nil {
+				t.Fatalf("ReadLogEntries() error = %v", err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Message)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("ReadLogEntries() messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadLogEntries(filepath.Join(t.TempDir(), InstallLogFile), LogFilter{}, 0); err == nil {
+		t.Error("ReadLogEntries() expected error for missing log file")
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), InstallLogFile)
+	logger, err := NewLogger(FATAL, path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetFormatter(JSONFormatter{})
+	logger.SetOperationID("op-1")
+	logger.Warn("update", "Failed to remove %s", "stale.md")
+	logger.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := ParseLogLine(strings.TrimSpace(string(data)))
+	if !ok {
+		t.Fatalf("ParseLogLine() could not parse %q", data)
+	}
+	want := LogEntry{Time: entry.Time, Level: "WARN", Component: "update", Message: "Failed to remove stale.md", OperationID: "op-1"}
+	if entry != want || entry.Time.IsZero() {
+		t.Errorf("ParseLogLine() = %+v, want %+v", entry, want)
+	}
+}
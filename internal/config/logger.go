@@ -1,12 +1,15 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"time"
+	"sync"
+
+	"github.com/google/uuid"
 )
 
 // LogLevel represents the logging level
@@ -25,27 +28,41 @@ const (
 	FATAL
 )
 
-var logLevelNames = map[LogLevel]string{
-	DEBUG: "DEBUG",
-	INFO:  "INFO",
-	WARN:  "WARN",
-	ERROR: "ERROR",
-	FATAL: "FATAL",
+// slogLevels maps each LogLevel to the slog level its records carry
+var slogLevels = map[LogLevel]slog.Level{
+	DEBUG: slog.LevelDebug,
+	INFO:  slog.LevelInfo,
+	WARN:  slog.LevelWarn,
+	ERROR: slog.LevelError,
+	FATAL: LevelFatal,
 }
 
-// Logger provides structured logging functionality
+// Logger provides structured logging functionality on top of log/slog. Each
+// message is a slog record with the message text, a ComponentKey attribute
+// and an OperationIDKey attribute.
 type Logger struct {
-	level      LogLevel
-	fileLogger *log.Logger
-	console    bool
-	logFile    *os.File
+	level            LogLevel
+	console          bool
+	stdout           io.Writer // Console output below ERROR, os.Stdout unless redirected
+	logFile          *os.File
+	fileFormatter    Formatter
+	consoleFormatter Formatter
+	operationID      string
+	handler          slog.Handler // Set by NewHandlerLogger instead of console and file
+	slog             *slog.Logger
 }
 
-// NewLogger creates a new logger instance
+// NewLogger creates a new logger instance. If logFilePath is given, messages
+// of every level are appended to that file, rotating it first if it has grown
+// past MaxLogSize; level only applies to the console.
 func NewLogger(level LogLevel, logFilePath string, console bool) (*Logger, error) {
 	logger := &Logger{
-		level:   level,
-		console: console,
+		level:            level,
+		console:          console,
+		stdout:           os.Stdout,
+		fileFormatter:    TextFormatter{},
+		consoleFormatter: consoleFormatter{},
+		operationID:      uuid.New().String(),
 	}
 
 	// Open log file if path is provided
@@ -56,6 +73,10 @@ func NewLogger(level LogLevel, logFilePath string, console bool) (*Logger, error
 			return nil, fmt.Errorf("failed to create log directory: %w", err)
 		}
 
+		if err := rotateLogFile(logFilePath); err != nil {
+			return nil, err
+		}
+
 		// Open log file in append mode
 		file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
@@ -63,12 +84,59 @@ func NewLogger(level LogLevel, logFilePath string, console bool) (*Logger, error
 		}
 
 		logger.logFile = file
-		logger.fileLogger = log.New(file, "", 0) // We'll format timestamps ourselves
 	}
 
+	logger.rebuild()
 	return logger, nil
 }
 
+// NewHandlerLogger creates a logger that passes every message to handler
+// instead of writing to the console or a file, for embedding the install
+// package in a program with its own logging. Use slog.Logger.Handler to log
+// to an existing *slog.Logger. Success messages have level LevelSuccess.
+func NewHandlerLogger(handler slog.Handler) *Logger {
+	logger := &Logger{
+		operationID: uuid.New().String(),
+		handler:     handler,
+	}
+	logger.rebuild()
+	return logger
+}
+
+// rebuild sets up the slog logger after the configuration changed
+func (l *Logger) rebuild() {
+	handler := l.handler
+	if handler == nil {
+		var handlers fanoutHandler
+		if l.logFile != nil {
+			handlers = append(handlers, &formatHandler{
+				mu:        &sync.Mutex{},
+				out:       l.logFile,
+				formatter: l.fileFormatter,
+				level:     slog.LevelDebug,
+			})
+		}
+		if l.console {
+			handlers = append(handlers, &formatHandler{
+				mu:        &sync.Mutex{},
+				out:       l.stdout,
+				errOut:    os.Stderr,
+				formatter: l.consoleFormatter,
+				level:     slogLevels[l.level],
+			})
+		}
+		handler = handlers
+	}
+
+	l.slog = slog.New(handler).With(OperationIDKey, l.operationID)
+}
+
+// Slog returns the underlying slog logger. Its records go to the same
+// console, file or handler as the messages of the Logger.
+func (l *Logger) Slog() *slog.Logger {
+	return l.slog
+}
+
 // Close closes the log file
 func (l *Logger) Close() error {
 	if l.logFile != nil {
@@ -78,31 +146,11 @@ func (l *Logger) Close() error {
 }
 
 // log writes a log message at the specified level
-func (l *Logger) log(level LogLevel, component, message string) {
-	if level < l.level {
-		return // Skip logs below current level
-	}
-
-	timestamp := time.Now().UTC().Format(time.RFC3339)
-	levelName := logLevelNames[level]
-	formattedMsg := fmt.Sprintf("[%s] [%s] [%s] %s", timestamp, levelName, component, message)
-
-	// Write to file if available
-	if l.fileLogger != nil {
-		l.fileLogger.Println(formattedMsg)
-	}
-
-	// Write to console if enabled
-	if l.console {
-		var output io.Writer = os.Stdout
-		if level >= ERROR {
-			output = os.Stderr
-		}
-		fmt.Fprintln(output, formatConsoleMessage(level, component, message))
-	}
+func (l *Logger) log(level slog.Level, component, message string) {
+	l.slog.Log(context.Background(), level, message, ComponentKey, component)
 
 	// Exit on FATAL
-	if level == FATAL {
+	if level == LevelFatal {
 		if l.logFile != nil {
 			l.logFile.Close()
 		}
@@ -111,18 +159,20 @@ func (l *Logger) log(level LogLevel, component, message string) {
 }
 
 // formatConsoleMessage formats a message for console output with colors/emojis
-func formatConsoleMessage(level LogLevel, component, message string) string {
+func formatConsoleMessage(level, component, message string) string {
 	var prefix string
 	switch level {
-	case DEBUG:
+	case "DEBUG":
 		prefix = "🔍 [DEBUG]"
-	case INFO:
+	case "INFO":
 		prefix = "ℹ️  [INFO]"
-	case WARN:
+	case "SUCCESS":
+		prefix = "✅ [SUCCESS]"
+	case "WARN":
 		prefix = "⚠️  [WARN]"
-	case ERROR:
+	case "ERROR":
 		prefix = "❌ [ERROR]"
-	case FATAL:
+	case "FATAL":
 		prefix = "💀 [FATAL]"
 	}
 
@@ -135,78 +185,74 @@ func formatConsoleMessage(level LogLevel, component, message string) string {
 // Debug logs a debug message
 func (l *Logger) Debug(component, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	l.log(DEBUG, component, message)
+	l.log(slog.LevelDebug, component, message)
 }
 
 // Info logs an informational message
 func (l *Logger) Info(component, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	l.log(INFO, component, message)
+	l.log(slog.LevelInfo, component, message)
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(component, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	l.log(WARN, component, message)
+	l.log(slog.LevelWarn, component, message)
 }
 
 // Error logs an error message
 func (l *Logger) Error(component, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	l.log(ERROR, component, message)
+	l.log(slog.LevelError, component, message)
 }
 
 // Fatal logs a fatal error and exits the program
 func (l *Logger) Fatal(component, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	l.log(FATAL, component, message)
+	l.log(LevelFatal, component, message)
 }
 
-// Success logs a success message (as INFO level with checkmark)
+// Success logs a success message (LevelSuccess, shown with a checkmark)
 func (l *Logger) Success(component, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	if l.console {
-		fmt.Printf("✅ [SUCCESS] [%s] %s\n", component, message)
-	}
-	l.log(INFO, component, "[SUCCESS] "+message)
+	l.log(LevelSuccess, component, message)
 }
 
-// SetLevel sets the minimum log level
-func (l *Logger) SetLevel(level LogLevel) {
-	l.level = level
+// SetOutput redirects console messages that would go to stdout, for example
+// to keep stdout free for machine-readable output
+func (l *Logger) SetOutput(w io.Writer) {
+	l.stdout = w
+	l.rebuild()
 }
 
-// GetLevel returns the current log level
-func (l *Logger) GetLevel() LogLevel {
-	return l.level
+// SetFormatter sets the format of the log file and of the console. By default
+// the log file is written with TextFormatter and the console with emoji
+// level markers.
+func (l *Logger) SetFormatter(f Formatter) {
+	l.fileFormatter = f
+	l.consoleFormatter = f
+	l.rebuild()
 }
 
-// Global logger instance
-var globalLogger *Logger
+// SetOperationID replaces the generated ID that correlates everything logged
+// by this logger, and the history entries recorded with it
+func (l *Logger) SetOperationID(id string) {
+	l.operationID = id
+	l.rebuild()
+}
 
-// InitGlobalLogger initializes the global logger
-func InitGlobalLogger(level LogLevel, logFilePath string, console bool) error {
-	logger, err := NewLogger(level, logFilePath, console)
-	if err != nil {
-		return err
-	}
-	globalLogger = logger
-	return nil
+// OperationID returns the ID that correlates everything logged by this logger
+func (l *Logger) OperationID() string {
+	return l.operationID
 }
 
-// GetGlobalLogger returns the global logger instance
-func GetGlobalLogger() *Logger {
-	if globalLogger == nil {
-		// Create a default logger if none exists
-		globalLogger, _ = NewLogger(INFO, "", true)
-	}
-	return globalLogger
+// SetLevel sets the minimum log level
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level = level
+	l.rebuild()
 }
 
-// CloseGlobalLogger closes the global logger
-func CloseGlobalLogger() error {
-	if globalLogger != nil {
-		return globalLogger.Close()
-	}
-	return nil
+// GetLevel returns the current log level
+func (l *Logger) GetLevel() LogLevel {
+	return l.level
 }
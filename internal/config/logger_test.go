@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+// recordingHandler keeps the records passed to it
+type recordingHandler struct {
+	records *[]slog.Record
+	attrs   []slog.Attr
+}
+
+func (h recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	record.AddAttrs(h.attrs...)
+	*h.records = append(*h.records, record)
+	return nil
+}
+
+func (h recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return h
+}
+
+func (h recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func TestNewHandlerLogger(t *testing.T) {
+	var records []slog.Record
+	logger := NewHandlerLogger(recordingHandler{records: &records})
+	logger.SetOperationID("op-1")
+
+	logger.Info("installer", "Installing %d files", 3)
+	logger.Success("installer", "Installed")
+
+	if len(records) != 2 {
+		t.Fatalf("handler got %d records, want 2", len(records))
+	}
+	if records[0].Level != slog.LevelInfo || records[0].Message != "Installing 3 files" {
+		t.Errorf("first record = %v %q", records[0].Level, records[0].Message)
+	}
+	if records[1].Level != LevelSuccess {
+		t.Errorf("Success() level = %v, want LevelSuccess", records[1].Level)
+	}
+
+	attrs := map[string]string{}
+	records[1].Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value.String()
+		return true
+	})
+	if attrs[ComponentKey] != "installer" || attrs[OperationIDKey] != "op-1" {
+		t.Errorf("record attributes = %v, want component and operation ID", attrs)
+	}
+}
+
+func TestLogger_Console(t *testing.T) {
+	var stdout bytes.Buffer
+	logger, err := NewLogger(INFO, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetOutput(&stdout)
+
+	logger.Debug("installer", "hidden")
+	logger.Info("installer", "Installing")
+	logger.Success("installer", "Installed")
+
+	want := "ℹ️  [INFO] [installer] Installing\n✅ [SUCCESS] [installer] Installed\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("console output = %q, want %q", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 // GetHomeDir returns the user's home directory in a cross-platform way
@@ -60,6 +61,16 @@ func GetVersionManifestPath(prefix string) string {
 	return filepath.Join(prefix, ".specify", "version-manifest.json")
 }
 
+// InstallLogFile is the name of the installation log file in the installation
+// directory. Rotated generations add a numeric suffix.
+const InstallLogFile = ".install-log.txt"
+
+// IsInstallLogFile reports whether name is the installation log file or one of
+// its rotated generations
+func IsInstallLogFile(name string) bool {
+	return strings.HasPrefix(name, InstallLogFile)
+}
+
 // GetInstallLogPath returns the path to the installation log file
 func GetInstallLogPath(prefix string) (string, error) {
 	if prefix == "" {
@@ -69,7 +80,7 @@ func GetInstallLogPath(prefix string) (string, error) {
 		}
 		prefix = defaultDir
 	}
-	return filepath.Join(prefix, ".install-log.txt"), nil
+	return filepath.Join(prefix, InstallLogFile), nil
 }
 
 // EnsureDir creates a directory and all parent directories if they don't exist
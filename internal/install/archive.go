@@ -0,0 +1,289 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+)
+
+// ExtractArchive extracts a .tar.gz, .tgz, .tar or .zip archive into destDir,
+// replacing any previous content.
+//
+// Entries that would be written outside destDir are rejected: absolute paths,
+// paths containing "..", paths that pass through a symlink, and symlinks whose
+// target resolves outside destDir. Hard links and special files are rejected.
+func ExtractArchive(archivePath, destDir string) error {
+	format, err := archiveFormat(archivePath)
+	if err != nil {
+		return err
+	}
+
+	// Extract next to the destination and swap it in once complete, so a
+	// rejected archive never leaves a partial tree behind
+	if err := config.EnsureDir(filepath.Dir(destDir)); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(destDir), "."+filepath.Base(destDir)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	switch format {
+	case "zip":
+		err = extractZip(archivePath, tmpDir)
+	default:
+		err = extractTar(archivePath, tmpDir, format == "tar.gz")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", archivePath, err)
+	}
+
+	if err := checkSymlinks(tmpDir); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", archivePath, err)
+	}
+
+	if err := os.RemoveAll(destDir); err != nil {
+		return fmt.Errorf("failed to remove previous extraction: %w", err)
+	}
+	if err := os.Rename(tmpDir, destDir); err != nil {
+		return fmt.Errorf("failed to move extracted files into place: %w", err)
+	}
+
+	return nil
+}
+
+// archiveFormat determines the archive format from the file name
+func archiveFormat(archivePath string) (string, error) {
+	name := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz", nil
+	case strings.HasSuffix(name, ".tar"):
+		return "tar", nil
+	case strings.HasSuffix(name, ".zip"):
+		return "zip", nil
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s (expected .tar.gz, .tgz, .tar or .zip)", archivePath)
+	}
+}
+
+func extractTar(archivePath, destDir string, gzipped bool) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = extractDir(destDir, header.Name)
+		case tar.TypeReg:
+			err = extractFile(destDir, header.Name, fs.FileMode(header.Mode), tr)
+		case tar.TypeSymlink:
+			err = extractSymlink(destDir, header.Name, header.Linkname)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			err = fmt.Errorf("unsupported entry type for %s", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(archivePath, destDir string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		mode := entry.Mode()
+		switch {
+		case mode.IsDir():
+			err = extractDir(destDir, entry.Name)
+		case mode&fs.ModeSymlink != 0:
+			err = extractZipSymlink(destDir, entry)
+		case mode.IsRegular():
+			err = extractZipFile(destDir, entry)
+		default:
+			err = fmt.Errorf("unsupported entry type for %s", entry.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractZipFile(destDir string, entry *zip.File) error {
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return extractFile(destDir, entry.Name, entry.Mode(), rc)
+}
+
+func extractZipSymlink(destDir string, entry *zip.File) error {
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return extractSymlink(destDir, entry.Name, string(target))
+}
+
+// entryPath validates an archive entry name and returns where it is extracted
+func entryPath(destDir, name string) (string, error) {
+	rel := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if rel == "." {
+		return destDir, nil
+	}
+	if path.IsAbs(rel) || !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+
+	// Refuse to write through a symlink created by an earlier entry
+	dir := destDir
+	parts := strings.Split(rel, "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("unsafe path in archive: %s passes through a symlink", name)
+		}
+	}
+
+	return filepath.Join(destDir, filepath.FromSlash(rel)), nil
+}
+
+func extractDir(destDir, name string) error {
+	target, err := entryPath(destDir, name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0755)
+}
+
+func extractFile(destDir, name string, mode fs.FileMode, content io.Reader) error {
+	target, err := entryPath(destDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	// Replace rather than write through whatever is already there
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	perm := fs.FileMode(0644)
+	if mode&0111 != 0 {
+		perm = 0755
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, content); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func extractSymlink(destDir, name, linkname string) error {
+	target, err := entryPath(destDir, name)
+	if err != nil {
+		return err
+	}
+
+	// The link target must stay inside the extraction directory
+	if linkname == "" || filepath.IsAbs(linkname) || path.IsAbs(linkname) {
+		return fmt.Errorf("unsafe symlink in archive: %s -> %s", name, linkname)
+	}
+	resolved := filepath.Join(filepath.Dir(target), filepath.FromSlash(linkname))
+	if rel, err := filepath.Rel(destDir, resolved); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("unsafe symlink in archive: %s -> %s", name, linkname)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(linkname), target)
+}
+
+// checkSymlinks resolves every extracted symlink and rejects any that lead
+// outside destDir, which lexical checks alone cannot rule out for chains of links
+func checkSymlinks(destDir string) error {
+	root, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(destDir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // Dangling links cannot expose anything
+			}
+			return err
+		}
+		if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+			name, _ := filepath.Rel(destDir, p)
+			return fmt.Errorf("unsafe symlink in archive: %s resolves outside the archive", filepath.ToSlash(name))
+		}
+		return nil
+	})
+}
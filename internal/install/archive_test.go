@@ -0,0 +1,227 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// archiveEntry describes one entry of a test archive
+type archiveEntry struct {
+	name    string
+	content string
+	link    string // Symlink target, if the entry is a symlink
+}
+
+func writeTarGz(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		switch {
+		case entry.link != "":
+			header = &tar.Header{Name: entry.name, Mode: 0777, Linkname: entry.link, Typeflag: tar.TypeSymlink}
+		case strings.HasSuffix(entry.name, "/"):
+			header = &tar.Header{Name: entry.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(0644)
+		content := entry.content
+		if entry.link != "" {
+			header.SetMode(fs.ModeSymlink | 0777)
+			content = entry.link
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	valid := []archiveEntry{
+		{name: "spec-kit-0.0.72/"},
+		{name: "spec-kit-0.0.72/templates/spec.md", content: "spec"},
+		{name: "spec-kit-0.0.72/memory/constitution.md", content: "constitution"},
+		{name: "spec-kit-0.0.72/templates/latest.md", link: "spec.md"},
+	}
+
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		wantErr string
+	}{
+		{name: "valid archive", entries: valid},
+		{
+			name:    "path traversal",
+			entries: []archiveEntry{{name: "../escape.md", content: "x"}},
+			wantErr: "unsafe path",
+		},
+		{
+			name:    "absolute path",
+			entries: []archiveEntry{{name: "/tmp/escape.md", content: "x"}},
+			wantErr: "unsafe path",
+		},
+		{
+			name:    "symlink outside archive",
+			entries: []archiveEntry{{name: "link", link: "../../etc"}},
+			wantErr: "unsafe symlink",
+		},
+		{
+			name:    "absolute symlink",
+			entries: []archiveEntry{{name: "link", link: "/etc/passwd"}},
+			wantErr: "unsafe symlink",
+		},
+		{
+			name: "write through symlink",
+			entries: []archiveEntry{
+				{name: "dir/"},
+				{name: "link", link: "dir"},
+				{name: "link/file.md", content: "x"},
+			},
+			wantErr: "passes through a symlink",
+		},
+		{
+			name: "symlink chain escaping archive",
+			entries: []archiveEntry{
+				{name: "a/b/"},
+				{name: "a/up", link: ".."},
+				{name: "a/b/up", link: "../up/.."},
+			},
+			wantErr: "resolves outside",
+		},
+	}
+
+	for _, format := range []string{"tar.gz", "zip"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				dir := t.TempDir()
+				archive := filepath.Join(dir, "spec-kit."+format)
+				if format == "zip" {
+					writeZip(t, archive, tt.entries)
+				} else {
+					writeTarGz(t, archive, tt.entries)
+				}
+
+				dest := filepath.Join(dir, "out", "spec-kit")
+				err := ExtractArchive(archive, dest)
+
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("ExtractArchive() error = %v, want %q", err, tt.wantErr)
+					}
+					if config.PathExists(dest) {
+						t.Error("ExtractArchive() left a partial extraction behind")
+					}
+					if config.PathExists(filepath.Join(dir, "escape.md")) {
+						t.Error("ExtractArchive() wrote outside the destination")
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("ExtractArchive() error = %v", err)
+				}
+				if got := readTestFile(t, filepath.Join(dest, "spec-kit-0.0.72", "templates", "latest.md")); got != "spec" {
+					t.Errorf("symlinked file content = %q, want %q", got, "spec")
+				}
+			})
+		}
+	}
+}
+
+func TestFetchDependency_Archive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "spec-kit-0.0.72.tar.gz")
+	writeTarGz(t, archive, []archiveEntry{
+		{name: "spec-kit-0.0.72/templates/spec.md", content: "spec"},
+	})
+
+	integrity, err := version.ComputeFileHash(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dep := &models.Dependency{
+		Version:     "0.0.72",
+		Source:      "archive",
+		InstallPath: ".specify",
+		Archive:     archive,
+		Subdir:      "spec-kit-0.0.72",
+		Integrity:   integrity,
+	}
+	paths := &InstallationPaths{CacheDir: filepath.Join(dir, ".cache")}
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+
+	source, err := FetchDependency("spec-kit", dep, &Source{Root: dir, FS: os.DirFS(dir)}, paths, logger)
+	if err != nil {
+		t.Fatalf("FetchDependency() error = %v", err)
+	}
+	if source.Source != "archive" {
+		t.Errorf("FetchDependency() source = %s, want archive", source.Source)
+	}
+	if got := readTestFile(t, filepath.Join(source.Name, "templates", "spec.md")); got != "spec" {
+		t.Errorf("extracted content = %q, want %q", got, "spec")
+	}
+
+	// A tampered archive is rejected before extraction
+	manifest := &models.Manifest{Dependencies: map[string]models.Dependency{"spec-kit": *dep}}
+	writeTarGz(t, archive, []archiveEntry{
+		{name: "spec-kit-0.0.72/templates/spec.md", content: "tampered"},
+	})
+	if _, err := fetchSpecKit(manifest, &Source{Root: dir}, paths, false, logger); err == nil || !strings.Contains(err.Error(), "integrity") {
+		t.Errorf("fetchSpecKit() error = %v, want integrity failure", err)
+	}
+	if got := readTestFile(t, filepath.Join(source.Name, "templates", "spec.md")); got != "spec" {
+		t.Errorf("tampered archive was extracted: content = %q", got)
+	}
+}
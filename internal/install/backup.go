@@ -1,66 +1,188 @@
 package install
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
 )
 
 // BackupInfo contains information about a backup
 type BackupInfo struct {
-	BackupPath    string
-	OriginalPath  string
-	CreatedAt     time.Time
-	BackupID      string
-	ComponentName string
+	BackupPath    string    `json:"backup_path"`
+	OriginalPath  string    `json:"original_path"`
+	CreatedAt     time.Time `json:"created_at"`
+	BackupID      string    `json:"backup_id"`
+	ComponentName string    `json:"component_name"`
+	Storage       string    `json:"storage"` // BackupStorageStore or BackupStorageDirectory
+
+	// Read from the backup metadata; empty for backups made without it
+	Trigger  string            `json:"trigger,omitempty"`
+	Label    string            `json:"label,omitempty"`
+	Versions map[string]string `json:"versions,omitempty"`
+	Size     int64             `json:"size,omitempty"`
+
+	objects map[string]int64 // Stored size of each object, set by pruneBackups
 }
 
-// CreateBackup creates a backup of an existing installation
-func CreateBackup(installPath string, logger *config.Logger) (*BackupInfo, error) {
+// ManualBackupTrigger is the trigger of a backup made on request with
+// BackupInstallation
+const ManualBackupTrigger = "backup"
+
+// Pinned reports whether a backup was made on request or given a label.
+// Retention policies leave pinned backups alone unless told otherwise.
+func (b *BackupInfo) Pinned() bool {
+	return b.Trigger == ManualBackupTrigger || b.Label != ""
+}
+
+// BackupOptions contains backup configuration
+type BackupOptions struct {
+	Trigger string        // Command that made the backup, e.g. "update"
+	Label   string        // Description shown when listing backups
+	Wait    time.Duration // How long BackupInstallation waits for another operation on the installation to finish
+}
+
+// backupClaudeDir is the directory of a backup that holds the Claude Code
+// files the installation owned, laid out as below ~/.claude. Backups made
+// before Claude Code files were backed up do not have it. In the backup
+// store it is a path prefix of the manifest entries.
+const backupClaudeDir = ".claude-files"
+
+// CreateBackup creates a backup of the files an existing installation owns,
+// including the agents and commands it installed in ~/.claude, in the backup
+// store next to the installation. Other files in the installation directory,
+// such as a project the installation lives in, are not backed up. Only files
+// that no earlier backup holds are stored.
+func CreateBackup(installPath string, opts BackupOptions, logger Logger) (*BackupInfo, error) {
+	// Resolve to an absolute path so the backup store is always a sibling of
+	// the installation directory, never nested inside it (e.g. for prefix ".")
+	installPath, err := config.ToAbsolutePath(installPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve installation path: %w", err)
+	}
+
 	// Check if installation exists
 	if !config.PathExists(installPath) {
 		return nil, fmt.Errorf("installation path does not exist: %s", installPath)
 	}
 
-	// Generate backup ID and path
-	timestamp := time.Now().UTC().Format("20060102-150405")
-	backupID := fmt.Sprintf("backup-%s", timestamp)
-	backupPath := installPath + "." + backupID
+	// Generate backup ID
+	now := time.Now().UTC()
+	store := newBackupStore(installPath)
+	backupID := store.uniqueBackupID(installPath, fmt.Sprintf("backup-%s", now.Format("20060102-150405")))
 
 	logger.Info("backup", "Creating backup of %s...", installPath)
-	logger.Debug("backup", "Backup destination: %s", backupPath)
+	logger.Debug("backup", "Backup store: %s", store.dir)
+
+	metadata := &BackupMetadata{
+		BackupID:     backupID,
+		CreatedAt:    now,
+		OriginalPath: installPath,
+		Trigger:      opts.Trigger,
+		Label:        opts.Label,
+		OperationID:  logger.OperationID(),
+		Storage:      BackupStorageStore,
+		Files:        []BackupFile{},
+	}
+	paths, err := GetPaths(installPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation paths: %w", err)
+	}
+	metadata.ClaudeDir = paths.ClaudeDir
+	if err := recordVersions(metadata, installPath, logger); err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
 
-	// Copy installation to backup location
-	if err := CopyDirectory(installPath, backupPath); err != nil {
+	added, err := backupOwnedEntries(paths, metadata, store)
+	if err != nil {
+		store.collectGarbage(logger)
 		return nil, fmt.Errorf("failed to create backup: %w", err)
 	}
 
-	// Get backup size
-	size, err := GetDirectorySize(backupPath)
+	claudeFiles, claudeAdded, err := backupClaudeFiles(installPath, metadata, store, logger)
 	if err != nil {
-		logger.Warn("backup", "Failed to calculate backup size: %v", err)
-	} else {
-		logger.Info("backup", "Backup size: %s", FormatSize(size))
+		store.collectGarbage(logger)
+		return nil, fmt.Errorf("failed to back up Claude Code files: %w", err)
 	}
+	logger.Debug("backup", "Backed up %d Claude Code file(s)", claudeFiles)
 
-	info := &BackupInfo{
-		BackupPath:    backupPath,
-		OriginalPath:  installPath,
-		CreatedAt:     time.Now().UTC(),
-		BackupID:      backupID,
-		ComponentName: "spec-kit-agents",
+	for _, file := range metadata.Files {
+		metadata.Size += file.Size
+	}
+	if err := store.writeManifest(metadata); err != nil {
+		store.collectGarbage(logger)
+		return nil, err
 	}
 
-	logger.Success("backup", "Backup created: %s", backupPath)
+	info := backupInfoFromMetadata(metadata, store.manifestPath(backupID))
+	logger.Info("backup", "Backup size: %s (%d of %d file(s) newly stored)",
+		FormatSize(metadata.Size), added+claudeAdded, len(metadata.Files))
+	logger.Success("backup", "Backup created: %s", backupID)
 
 	return info, nil
 }
 
-// RestoreBackup restores an installation from a backup
-func RestoreBackup(backup *BackupInfo, logger *config.Logger) error {
+// BackupInstallation backs up an installation on request, holding the
+// operation lock while it does. The trigger defaults to ManualBackupTrigger,
+// which pins the backup.
+func BackupInstallation(prefix string, opts BackupOptions, logger Logger) (*BackupInfo, error) {
+	if opts.Trigger == "" {
+		opts.Trigger = ManualBackupTrigger
+	}
+
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	// Keep installs, updates and uninstalls from changing files being copied
+	opLock, err := AcquireOperationLock(paths, "backup", opts.Wait, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer opLock.Release()
+
+	// Complete or undo an installation that was interrupted
+	if err := RecoverTransaction(paths, logger); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted installation: %w", err)
+	}
+
+	if !config.PathExists(paths.VersionLock) {
+		return nil, fmt.Errorf("%w at %s", ErrNotInstalled, prefix)
+	}
+
+	return CreateBackup(prefix, opts, logger)
+}
+
+// backupInfoFromMetadata describes a backup in the backup store
+func backupInfoFromMetadata(metadata *BackupMetadata, manifestPath string) *BackupInfo {
+	return &BackupInfo{
+		BackupPath:    manifestPath,
+		OriginalPath:  metadata.OriginalPath,
+		CreatedAt:     metadata.CreatedAt,
+		BackupID:      metadata.BackupID,
+		ComponentName: "spec-kit-agents",
+		Storage:       BackupStorageStore,
+		Label:         metadata.Label,
+		Trigger:       metadata.Trigger,
+		Versions:      metadata.Versions,
+		Size:          metadata.Size,
+	}
+}
+
+// RestoreBackup restores an installation from a backup. The files the current
+// installation owns are replaced by those the backed-up installation owned,
+// and nothing else in the installation directory, .specify/ or ~/.claude is
+// touched. Backups without Claude
+// Code files leave ~/.claude as it is.
+func RestoreBackup(backup *BackupInfo, logger Logger) error {
 	logger.Info("backup", "Restoring from backup: %s", backup.BackupID)
 
 	// Verify backup exists
@@ -68,18 +190,84 @@ func RestoreBackup(backup *BackupInfo, logger *config.Logger) error {
 		return fmt.Errorf("backup does not exist: %s", backup.BackupPath)
 	}
 
-	// Remove current installation if it exists
-	if config.PathExists(backup.OriginalPath) {
-		logger.Debug("backup", "Removing current installation...")
-		if err := os.RemoveAll(backup.OriginalPath); err != nil {
-			return fmt.Errorf("failed to remove current installation: %w", err)
+	// Extract a backup from the store before anything is removed
+	backupPath := backup.BackupPath
+	if backup.Storage == BackupStorageStore {
+		metadata, err := readManifest(backup.BackupPath)
+		if err != nil {
+			return err
 		}
+		store := newBackupStore(backup.OriginalPath)
+		backupPath, err = store.extractTemp(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to extract backup: %w", err)
+		}
+		defer os.RemoveAll(backupPath)
 	}
 
-	// Restore backup
+	paths, err := GetPaths(backup.OriginalPath)
+	if err != nil {
+		return fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	// Refuse a backup with Claude Code files its version lock does not own
+	// before anything is changed
+	claudeFiles, err := backupClaudeFilesToRestore(backupPath, paths)
+	if err != nil {
+		return err
+	}
+
+	// Collect what the current installation owns before its version lock is
+	// replaced
+	var current *models.VersionLock
+	var currentClaudeFiles []string
+	if config.PathExists(paths.VersionLock) {
+		current, err = version.LoadVersionLockFromPath(paths.VersionLock)
+		if err == nil {
+			currentClaudeFiles, err = FindOwnedClaudeFiles(paths, current)
+		}
+		if err != nil {
+			logger.Warn("backup", "Failed to find the files of the current installation: %v", err)
+		}
+	}
+
+	// Replace the files the current installation owns, keeping the operation
+	// lock held by the caller and everything the installer did not write
 	logger.Debug("backup", "Copying backup to original location...")
-	if err := CopyDirectory(backup.BackupPath, backup.OriginalPath); err != nil {
-		return fmt.Errorf("failed to restore backup: %w", err)
+	for _, name := range ownedInstallEntries(paths) {
+		src := filepath.Join(backupPath, name)
+		dst := filepath.Join(paths.Prefix, name)
+		if dst == paths.SpecifyDir {
+			if err := restoreSpecifyFiles(backupPath, paths, current, logger); err != nil {
+				return fmt.Errorf("failed to restore backup: %w", err)
+			}
+			continue
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return fmt.Errorf("failed to remove current installation: %w", err)
+		}
+		var err error
+		switch {
+		case config.IsDirectory(src):
+			err = CopyDirectory(src, dst)
+		case config.PathExists(src):
+			err = CopyFile(src, dst)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+	}
+
+	restored, err := restoreClaudeFiles(backupPath, paths, currentClaudeFiles, claudeFiles, logger)
+	if err != nil {
+		return fmt.Errorf("failed to restore Claude Code files: %w", err)
+	}
+	if restored > 0 {
+		logger.Info("backup", "Restored %d Claude Code file(s)", restored)
+	}
+
+	if err := registerInstallation(paths); err != nil {
+		logger.Warn("backup", "Failed to record the installation: %v", err)
 	}
 
 	logger.Success("backup", "Backup restored successfully")
@@ -87,8 +275,308 @@ func RestoreBackup(backup *BackupInfo, logger *config.Logger) error {
 	return nil
 }
 
-// CleanupBackup removes a backup
-func CleanupBackup(backup *BackupInfo, logger *config.Logger) error {
+// ownedClaudeFiles returns the Claude Code files owned by the installation at
+// paths, or none if it has no version lock
+func ownedClaudeFiles(paths *InstallationPaths) ([]string, error) {
+	if !config.PathExists(paths.VersionLock) {
+		return nil, nil
+	}
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		return nil, err
+	}
+	return FindOwnedClaudeFiles(paths, lock)
+}
+
+// backupClaudeFiles adds the Claude Code files owned by the installation at
+// installPath to the backup. It returns the number of files backed up and of
+// new objects stored.
+func backupClaudeFiles(installPath string, metadata *BackupMetadata, store *backupStore, logger Logger) (int, int, error) {
+	paths, err := GetPaths(installPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	files, err := ownedClaudeFiles(paths)
+	if err != nil {
+		// The installation can still be backed up without them
+		logger.Warn("backup", "Failed to find the installed Claude Code files, backing up %s only: %v", installPath, err)
+		return 0, 0, nil
+	}
+
+	// Recorded even when empty, so that restoring removes the files of a
+	// newer installation
+	metadata.Dirs = append(metadata.Dirs, backupClaudeDir)
+	added := 0
+	for _, file := range files {
+		rel, err := filepath.Rel(paths.ClaudeDir, file)
+		if err != nil {
+			return 0, 0, err
+		}
+		entry, isNew, err := store.addFile(file, path.Join(backupClaudeDir, filepath.ToSlash(rel)))
+		if err != nil {
+			return 0, 0, err
+		}
+		metadata.Files = append(metadata.Files, entry)
+		if isNew {
+			added++
+		}
+	}
+
+	return len(files), added, nil
+}
+
+// backupClaudeFilesToRestore returns the Claude Code files of a backup,
+// relative to the Claude directory, or nil if the backup has none. Every file
+// must be an agent or command owned by the backed-up version lock, so that a
+// backup, which may have been imported, cannot write anything else into
+// ~/.claude.
+func backupClaudeFilesToRestore(backupPath string, paths *InstallationPaths) ([]string, error) {
+	backupDir := filepath.Join(backupPath, backupClaudeDir)
+	if !config.IsDirectory(backupDir) {
+		return nil, nil
+	}
+
+	lock, err := readBackupVersionLock(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	owned := map[string]bool{}
+	if lock != nil && len(lock.Files) > 0 {
+		for _, dir := range []string{paths.ClaudeAgents, paths.ClaudeCommands} {
+			for _, file := range OwnedFilesUnder(lock, dir) {
+				if rel, err := filepath.Rel(paths.ClaudeDir, file.Path); err == nil {
+					owned[rel] = true
+				}
+			}
+		}
+	}
+
+	files, err := listFilesRecursive(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	rels := make([]string, 0, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(backupDir, file)
+		if err != nil {
+			return nil, err
+		}
+		if !owned[rel] && !(lock != nil && len(lock.Files) == 0 && isPrefixedClaudeFile(rel)) {
+			return nil, fmt.Errorf("%w: backup holds %s, which is not an agent or command its installation owned",
+				ErrBackupCorrupt, filepath.ToSlash(filepath.Join(backupClaudeDir, rel)))
+		}
+		rels = append(rels, rel)
+	}
+
+	return rels, nil
+}
+
+// isPrefixedClaudeFile reports whether a path relative to the Claude
+// directory is an agent or command named as the installer names them, which
+// is how locks written before file ownership was tracked identify them
+func isPrefixedClaudeFile(rel string) bool {
+	dir, name := filepath.Split(rel)
+	switch filepath.Clean(dir) {
+	case "agents":
+		return strings.HasPrefix(name, "cat-") && strings.HasSuffix(name, ".md")
+	case "commands":
+		return strings.HasPrefix(name, "speckit.") && strings.HasSuffix(name, ".md")
+	default:
+		return false
+	}
+}
+
+// readBackupVersionLock reads the version lock of an extracted backup without
+// migrating it, or returns nil if the backup has none
+func readBackupVersionLock(backupPath string) (*models.VersionLock, error) {
+	lockPath, err := config.GetVersionLockPath(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read backed-up version lock: %w", err)
+	}
+
+	var lock models.VersionLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("%w: invalid version lock: %v", ErrBackupCorrupt, err)
+	}
+	return &lock, nil
+}
+
+// restoreClaudeFiles replaces the Claude Code files of the current
+// installation with the files of the backup, as returned by
+// backupClaudeFilesToRestore. It returns the number of files restored.
+func restoreClaudeFiles(backupPath string, paths *InstallationPaths, current, files []string, logger Logger) (int, error) {
+	backupDir := filepath.Join(backupPath, backupClaudeDir)
+	if !config.IsDirectory(backupDir) {
+		logger.Debug("backup", "Backup has no Claude Code files, leaving %s as it is", paths.ClaudeDir)
+		return 0, nil
+	}
+
+	// Files the current installation added or changed since the backup
+	for _, file := range current {
+		logger.Debug("backup", "Removing %s", file)
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+
+	for _, rel := range files {
+		if err := CopyFile(filepath.Join(backupDir, rel), filepath.Join(paths.ClaudeDir, rel)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(files), nil
+}
+
+// isRuntimeFile reports whether an entry of the installation directory belongs
+// to running the tool rather than to the installation: the operation lock,
+// which belongs to the running process, and the install log, which records
+// every version. Backups leave them out and restoring one keeps them.
+func isRuntimeFile(name string) bool {
+	return name == operationLockName || config.IsInstallLogFile(name)
+}
+
+// ownedInstallEntries returns the entries of an installation directory that
+// the installer owns: .specify/, where every installed file outside ~/.claude
+// lives, the version lock and its previous generation, and the pristine
+// store. Backups hold only these, and restoring one replaces only these, so
+// an installation in a project directory never touches the project.
+func ownedInstallEntries(paths *InstallationPaths) []string {
+	return []string{
+		filepath.Base(paths.SpecifyDir),
+		filepath.Base(paths.VersionLock),
+		filepath.Base(models.VersionLockBackupPath(paths.VersionLock)),
+		filepath.Base(paths.PristineDir),
+	}
+}
+
+// backupOwnedEntries stores the entries of the installation at paths that
+// the installer owns. It returns the number of new objects written.
+func backupOwnedEntries(paths *InstallationPaths, metadata *BackupMetadata, store *backupStore) (int, error) {
+	added := 0
+	for _, name := range ownedInstallEntries(paths) {
+		full := filepath.Join(paths.Prefix, name)
+		switch {
+		case config.IsDirectory(full):
+			metadata.Dirs = append(metadata.Dirs, name)
+			n, err := store.addTree(metadata, full, name)
+			if err != nil {
+				return 0, err
+			}
+			added += n
+		case config.PathExists(full):
+			file, isNew, err := store.addFile(full, name)
+			if err != nil {
+				return 0, err
+			}
+			metadata.Files = append(metadata.Files, file)
+			if isNew {
+				added++
+			}
+		}
+	}
+	return added, nil
+}
+
+// restoreSpecifyFiles replaces the files below .specify/ that the current
+// installation owns with those the backed-up installation owned. Everything
+// else below .specify/, such as the specs the user wrote, is left alone, and
+// so are files in the userSpecifyDirs that still exist.
+func restoreSpecifyFiles(backupPath string, paths *InstallationPaths, current *models.VersionLock, logger Logger) error {
+	files, err := backupSpecifyFiles(backupPath, paths)
+	if err != nil {
+		return err
+	}
+
+	if current != nil {
+		owned, err := FindOwnedSpecifyFiles(paths, current)
+		if err != nil {
+			return err
+		}
+		for _, file := range owned {
+			if inUserSpecifyDir(paths, file) {
+				continue
+			}
+			logger.Debug("backup", "Removing %s", file)
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	backupDir := filepath.Join(backupPath, filepath.Base(paths.SpecifyDir))
+	for _, rel := range files {
+		dst := filepath.Join(paths.SpecifyDir, rel)
+		if inUserSpecifyDir(paths, dst) && config.PathExists(dst) {
+			logger.Debug("backup", "Keeping %s, which is yours to edit", dst)
+			continue
+		}
+		if err := CopyFile(filepath.Join(backupDir, rel), dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backupSpecifyFiles returns the files below .specify/ in a backup that the
+// backed-up installation owned, relative to .specify/. As with
+// FindOwnedSpecifyFiles the backed-up version lock is authoritative; older
+// locks fall back to the installer's own directories and the version
+// manifest. Owned files missing from the backup are left out.
+func backupSpecifyFiles(backupPath string, paths *InstallationPaths) ([]string, error) {
+	backupDir := filepath.Join(backupPath, filepath.Base(paths.SpecifyDir))
+
+	lock, err := readBackupVersionLock(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if lock != nil && len(lock.Files) > 0 {
+		for _, file := range OwnedFilesUnder(lock, paths.SpecifyDir) {
+			rel, err := filepath.Rel(paths.SpecifyDir, file.Path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, rel)
+		}
+	} else {
+		for _, dir := range installerSpecifyDirs {
+			found, err := listFilesRecursive(filepath.Join(backupDir, dir))
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range found {
+				rel, err := filepath.Rel(backupDir, file)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, rel)
+			}
+		}
+		files = append(files, filepath.Base(paths.VersionManifest))
+	}
+
+	rels := make([]string, 0, len(files))
+	for _, rel := range files {
+		if config.PathExists(filepath.Join(backupDir, rel)) {
+			rels = append(rels, rel)
+		}
+	}
+	return rels, nil
+}
+
+// CleanupBackup removes a backup. For a backup in the backup store, the
+// objects no other backup refers to are removed with it.
+func CleanupBackup(backup *BackupInfo, logger Logger) error {
 	if backup == nil || backup.BackupPath == "" {
 		return nil
 	}
@@ -102,11 +590,23 @@ func CleanupBackup(backup *BackupInfo, logger *config.Logger) error {
 		logger.Info("backup", "Backup cleaned up: %s", backup.BackupID)
 	}
 
+	if backup.Storage == BackupStorageStore {
+		if _, err := newBackupStore(backup.OriginalPath).collectGarbage(logger); err != nil {
+			logger.Warn("backup", "Failed to remove unused backup objects: %v", err)
+		}
+	}
+
 	return nil
 }
 
-// ListBackups finds all backups for a given installation path
+// ListBackups finds all backups for a given installation path, in the backup
+// store and in backup directories made by older releases
 func ListBackups(installPath string) ([]*BackupInfo, error) {
+	installPath, err := config.ToAbsolutePath(installPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve installation path: %w", err)
+	}
+
 	// Get parent directory
 	parentDir := filepath.Dir(installPath)
 	baseName := filepath.Base(installPath)
@@ -141,19 +641,67 @@ func ListBackups(installPath string) ([]*BackupInfo, error) {
 				continue
 			}
 
-			backups = append(backups, &BackupInfo{
+			backup := &BackupInfo{
 				BackupPath:    backupPath,
 				OriginalPath:  installPath,
 				CreatedAt:     info.ModTime(),
 				BackupID:      backupID,
 				ComponentName: "spec-kit-agents",
-			})
+				Storage:       BackupStorageDirectory,
+			}
+			if metadata, err := readBackupMetadata(backupPath); err == nil {
+				backup.CreatedAt = metadata.CreatedAt
+				backup.Label = metadata.Label
+				backup.Trigger = metadata.Trigger
+				backup.Versions = metadata.Versions
+				backup.Size = metadata.Size
+			}
+			backups = append(backups, backup)
+		}
+	}
+
+	manifests, err := newBackupStore(installPath).manifestPaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup store: %w", err)
+	}
+	for _, manifestPath := range manifests {
+		metadata, err := readManifest(manifestPath)
+		if err != nil {
+			// Still listed, so that verifying it reports the damage
+			info, statErr := os.Stat(manifestPath)
+			if statErr != nil {
+				continue
+			}
+			metadata = &BackupMetadata{
+				BackupID:     strings.TrimSuffix(filepath.Base(manifestPath), ".json"),
+				CreatedAt:    info.ModTime(),
+				OriginalPath: installPath,
+			}
 		}
+		backup := backupInfoFromMetadata(metadata, manifestPath)
+		backup.OriginalPath = installPath
+		backups = append(backups, backup)
 	}
 
 	return backups, nil
 }
 
+// FindBackup returns the backup of an installation with the given ID
+func FindBackup(installPath, backupID string) (*BackupInfo, error) {
+	backups, err := ListBackups(installPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	for _, backup := range backups {
+		if backup.BackupID == backupID {
+			return backup, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, backupID)
+}
+
 // GetLatestBackup returns the most recent backup
 func GetLatestBackup(installPath string) (*BackupInfo, error) {
 	backups, err := ListBackups(installPath)
@@ -162,7 +710,7 @@ func GetLatestBackup(installPath string) (*BackupInfo, error) {
 	}
 
 	if len(backups) == 0 {
-		return nil, fmt.Errorf("no backups found")
+		return nil, fmt.Errorf("%w: no backups of %s", ErrBackupNotFound, installPath)
 	}
 
 	// Find most recent backup
@@ -175,31 +723,3 @@ func GetLatestBackup(installPath string) (*BackupInfo, error) {
 
 	return latest, nil
 }
-
-// CleanupOldBackups removes backups older than a certain duration
-func CleanupOldBackups(installPath string, keepDuration time.Duration, logger *config.Logger) error {
-	backups, err := ListBackups(installPath)
-	if err != nil {
-		return err
-	}
-
-	cutoffTime := time.Now().UTC().Add(-keepDuration)
-	removedCount := 0
-
-	for _, backup := range backups {
-		if backup.CreatedAt.Before(cutoffTime) {
-			logger.Debug("backup", "Removing old backup: %s (created %s)", backup.BackupID, backup.CreatedAt.Format(time.RFC3339))
-			if err := CleanupBackup(backup, logger); err != nil {
-				logger.Warn("backup", "Failed to cleanup backup %s: %v", backup.BackupID, err)
-			} else {
-				removedCount++
-			}
-		}
-	}
-
-	if removedCount > 0 {
-		logger.Info("backup", "Cleaned up %d old backup(s)", removedCount)
-	}
-
-	return nil
-}
@@ -0,0 +1,438 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// simulateNewerInstall changes an owned agent and adds a new one, as an
+// update would
+func simulateNewerInstall(t *testing.T, paths *InstallationPaths) {
+	t.Helper()
+
+	writeTestFile(t, filepath.Join(paths.ClaudeAgents, "cat-documentation.md"), "new agent")
+	added := filepath.Join(paths.ClaudeAgents, "cat-added.md")
+	writeTestFile(t, added, "added agent")
+
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, size, err := HashFile(added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.AddFiles([]models.OwnedFile{{Path: added, Size: size, SHA256: hash}})
+	if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extractTestBackup extracts a backup from the backup store into a temporary
+// directory
+func extractTestBackup(t *testing.T, backup *BackupInfo) string {
+	t.Helper()
+
+	metadata, err := readManifest(backup.BackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := newBackupStore(backup.OriginalPath).extract(metadata, dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// backupObjectPath returns the stored object of a file in a backup
+func backupObjectPath(t *testing.T, backup *BackupInfo, rel string) string {
+	t.Helper()
+
+	metadata, err := readManifest(backup.BackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range metadata.Files {
+		if file.Path == rel {
+			return newBackupStore(backup.OriginalPath).objectPath(file.SHA256)
+		}
+	}
+	t.Fatalf("backup %s has no %s", backup.BackupID, rel)
+	return ""
+}
+
+// createDirectoryBackup copies the installation into a backup directory, as
+// releases before the backup store did
+func createDirectoryBackup(t *testing.T, paths *InstallationPaths) *BackupInfo {
+	t.Helper()
+
+	if err := CopyDirectory(paths.Prefix, paths.Prefix+".backup-20251022-120000"); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := FindBackup(paths.Prefix, "backup-20251022-120000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+func TestRestoreBackup_ClaudeFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	simulateNewerInstall(t, paths)
+
+	if err := RestoreBackup(backup, logger); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(paths.ClaudeAgents, "cat-documentation.md")); got != "agent" {
+		t.Errorf("restored agent = %q, want %q", got, "agent")
+	}
+	if got := readTestFile(t, filepath.Join(paths.ClaudeCommands, "speckit.specify.md")); got != "command" {
+		t.Errorf("restored command = %q, want %q", got, "command")
+	}
+	if config.PathExists(filepath.Join(paths.ClaudeAgents, "cat-added.md")) {
+		t.Error("RestoreBackup() kept an agent the backup did not have")
+	}
+	if got := readTestFile(t, filepath.Join(paths.ClaudeAgents, "my-agent.md")); got != "user agent" {
+		t.Errorf("user agent = %q, want it untouched", got)
+	}
+	if config.PathExists(filepath.Join(paths.Prefix, backupClaudeDir)) {
+		t.Error("RestoreBackup() copied the Claude Code files into the installation directory")
+	}
+	if config.PathExists(filepath.Join(paths.Prefix, backupMetadataFile)) {
+		t.Error("RestoreBackup() copied the backup metadata into the installation directory")
+	}
+}
+
+func TestRestoreBackup_WithoutClaudeFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	// As made by releases that only backed up the installation directory
+	backup := createDirectoryBackup(t, paths)
+	simulateNewerInstall(t, paths)
+
+	if err := RestoreBackup(backup, logger); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(paths.ClaudeAgents, "cat-documentation.md")); got != "new agent" {
+		t.Errorf("agent = %q, want it untouched", got)
+	}
+	if !config.PathExists(filepath.Join(paths.ClaudeAgents, "cat-added.md")) {
+		t.Error("RestoreBackup() removed an agent without a backup to restore")
+	}
+}
+
+func TestCreateBackup_Metadata(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{Trigger: "update"}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	if backup.Storage != BackupStorageStore {
+		t.Errorf("Storage = %q, want %q", backup.Storage, BackupStorageStore)
+	}
+	metadata, err := readManifest(backup.BackupPath)
+	if err != nil {
+		t.Fatalf("readManifest() error = %v", err)
+	}
+	if metadata.Trigger != "update" {
+		t.Errorf("Trigger = %q, want %q", metadata.Trigger, "update")
+	}
+	if metadata.Versions["spec-kit-agents"] != "2.0.0" {
+		t.Errorf("Versions = %v, want spec-kit-agents 2.0.0", metadata.Versions)
+	}
+	if metadata.InstallationID == "" || metadata.OperationID != logger.OperationID() {
+		t.Errorf("InstallationID = %q, OperationID = %q", metadata.InstallationID, metadata.OperationID)
+	}
+
+	var size int64
+	found := false
+	for _, file := range metadata.Files {
+		size += file.Size
+		if file.Path == backupClaudeDir+"/agents/cat-documentation.md" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Files = %v, want the backed-up agent", metadata.Files)
+	}
+	if metadata.Size != size || backup.Size != size {
+		t.Errorf("Size = %d (info %d), want %d", metadata.Size, backup.Size, size)
+	}
+
+	backups, err := ListBackups(paths.Prefix)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups() = %v, %v", backups, err)
+	}
+	if backups[0].Trigger != "update" || !backups[0].CreatedAt.Equal(metadata.CreatedAt) {
+		t.Errorf("ListBackups() = %+v, want the metadata", backups[0])
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	verification, err := VerifyBackup(backup)
+	if err != nil {
+		t.Fatalf("VerifyBackup() error = %v", err)
+	}
+	if err := verification.Err(); err != nil || verification.Checked == 0 {
+		t.Fatalf("VerifyBackup() = %+v, %v, want an intact backup", verification, err)
+	}
+
+	writeTestFile(t, backupObjectPath(t, backup, ".specify/templates/spec.md"), "tampered")
+	if err := os.Remove(backupObjectPath(t, backup, backupClaudeDir+"/agents/cat-documentation.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	verification, err = VerifyBackup(backup)
+	if err != nil {
+		t.Fatalf("VerifyBackup() error = %v", err)
+	}
+	if len(verification.Modified) != 1 || verification.Modified[0] != ".specify/templates/spec.md" {
+		t.Errorf("Modified = %v", verification.Modified)
+	}
+	if len(verification.Missing) != 1 || verification.Missing[0] != backupClaudeDir+"/agents/cat-documentation.md" {
+		t.Errorf("Missing = %v", verification.Missing)
+	}
+	if err := verification.Err(); !errors.Is(err, ErrBackupCorrupt) {
+		t.Errorf("Err() = %v, want ErrBackupCorrupt", err)
+	}
+}
+
+func TestVerifyBackup_Directory(t *testing.T) {
+	paths, _ := setupFakeInstallation(t)
+	backup := createDirectoryBackup(t, paths)
+
+	if _, err := VerifyBackup(backup); !errors.Is(err, ErrNoBackupMetadata) {
+		t.Errorf("VerifyBackup() error = %v, want ErrNoBackupMetadata", err)
+	}
+
+	// Directory backups with backup.json, as made before the backup store
+	files, err := hashBackupFiles(backup.BackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(BackupMetadata{BackupID: backup.BackupID, Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(backup.BackupPath, backupMetadataFile), string(data))
+	writeTestFile(t, filepath.Join(backup.BackupPath, ".specify", "templates", "spec.md"), "tampered")
+	writeTestFile(t, filepath.Join(backup.BackupPath, "extra.md"), "extra")
+
+	verification, err := VerifyBackup(backup)
+	if err != nil {
+		t.Fatalf("VerifyBackup() error = %v", err)
+	}
+	if len(verification.Modified) != 1 || verification.Modified[0] != ".specify/templates/spec.md" {
+		t.Errorf("Modified = %v", verification.Modified)
+	}
+	if len(verification.Extra) != 1 || verification.Extra[0] != "extra.md" {
+		t.Errorf("Extra = %v", verification.Extra)
+	}
+}
+
+func TestRollback_RefusesCorruptBackup(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	simulateNewerInstall(t, paths)
+	if err := os.Remove(backupObjectPath(t, backup, backupClaudeDir+"/agents/cat-documentation.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Rollback(paths.Prefix, RollbackOptions{BackupID: backup.BackupID}, logger)
+	if !errors.Is(err, ErrBackupCorrupt) {
+		t.Fatalf("Rollback() error = %v, want ErrBackupCorrupt", err)
+	}
+	if got := readTestFile(t, filepath.Join(paths.ClaudeAgents, "cat-documentation.md")); got != "new agent" {
+		t.Errorf("agent = %q, want the installation untouched", got)
+	}
+}
+
+func TestCreateBackup_Deduplicates(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+	store := newBackupStore(paths.Prefix)
+	countObjects := func() int {
+		objects, err := listFilesRecursive(filepath.Join(store.dir, storeObjectsDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(objects)
+	}
+
+	first, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	objects := countObjects()
+
+	// Nothing changed, nothing new to store
+	second, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	if second.BackupID == first.BackupID {
+		t.Fatalf("CreateBackup() reused backup ID %s", first.BackupID)
+	}
+	if got := countObjects(); got != objects {
+		t.Errorf("objects after unchanged backup = %d, want %d", got, objects)
+	}
+
+	writeTestFile(t, filepath.Join(paths.SpecifyDir, "templates", "spec.md"), "changed spec")
+	if _, err := CreateBackup(paths.Prefix, BackupOptions{}, logger); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	if got := countObjects(); got != objects+1 {
+		t.Errorf("objects after changing one file = %d, want %d", got, objects+1)
+	}
+
+	// Objects still used by other backups survive removing one
+	if err := CleanupBackup(first, logger); err != nil {
+		t.Fatalf("CleanupBackup() error = %v", err)
+	}
+	verification, err := VerifyBackup(second)
+	if err != nil || verification.Err() != nil {
+		t.Errorf("VerifyBackup() after removing another backup = %+v, %v", verification, err)
+	}
+	if got := readTestFile(t, filepath.Join(extractTestBackup(t, second), ".specify", "templates", "spec.md")); got != "spec" {
+		t.Errorf("extracted spec = %q, want %q", got, "spec")
+	}
+
+	backups, err := ListBackups(paths.Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, backup := range backups {
+		if err := CleanupBackup(backup, logger); err != nil {
+			t.Fatalf("CleanupBackup() error = %v", err)
+		}
+	}
+	if got := countObjects(); got != 0 {
+		t.Errorf("objects after removing every backup = %d, want 0", got)
+	}
+}
+
+func TestRestoreBackup_LeavesUnownedFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	// An installation in a project directory, as with --prefix .
+	mainFile := filepath.Join(paths.Prefix, "src", "main.go")
+	writeTestFile(t, mainFile, "package main")
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	metadata, err := readManifest(backup.BackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range metadata.Files {
+		if file.Path == "src/main.go" {
+			t.Errorf("CreateBackup() backed up %s, which the installation does not own", file.Path)
+		}
+	}
+
+	writeTestFile(t, mainFile, "package main // changed")
+	newFile := filepath.Join(paths.Prefix, "src", "new.go")
+	writeTestFile(t, newFile, "package main")
+	writeTestFile(t, filepath.Join(paths.SpecifyDir, "templates", "spec.md"), "new spec")
+
+	if err := RestoreBackup(backup, logger); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(paths.SpecifyDir, "templates", "spec.md")); got != "spec" {
+		t.Errorf("restored spec = %q, want %q", got, "spec")
+	}
+	if got := readTestFile(t, mainFile); got != "package main // changed" {
+		t.Errorf("src/main.go = %q, want it untouched", got)
+	}
+	if !config.PathExists(newFile) {
+		t.Error("RestoreBackup() removed a file the installation does not own")
+	}
+}
+
+func TestRestoreBackup_RefusesUnownedClaudeFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	// A backup directory holding a file outside the agents and commands
+	backup := createDirectoryBackup(t, paths)
+	writeTestFile(t, filepath.Join(backup.BackupPath, backupClaudeDir, "agents", "cat-documentation.md"), "agent")
+	writeTestFile(t, filepath.Join(backup.BackupPath, backupClaudeDir, "settings.json"), `{"hooks": {}}`)
+
+	err := RestoreBackup(backup, logger)
+	if !errors.Is(err, ErrBackupCorrupt) {
+		t.Fatalf("RestoreBackup() error = %v, want ErrBackupCorrupt", err)
+	}
+	if config.PathExists(filepath.Join(paths.ClaudeDir, "settings.json")) {
+		t.Error("RestoreBackup() wrote a file the installation did not own into the Claude directory")
+	}
+	if !config.PathExists(paths.VersionLock) {
+		t.Error("RestoreBackup() changed the installation before refusing the backup")
+	}
+}
+
+func TestRestoreBackup_KeepsUserSpecifyFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	constitution := filepath.Join(paths.SpecifyDir, "memory", "constitution.md")
+	writeTestFile(t, constitution, "# Constitution")
+	recordAllFiles(t, paths)
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	spec := filepath.Join(paths.SpecifyDir, "specs", "x.md")
+	writeTestFile(t, spec, "# Spec")
+	writeTestFile(t, constitution, "# Constitution, amended")
+	writeTestFile(t, filepath.Join(paths.SpecifyDir, "templates", "spec.md"), "new spec")
+
+	if err := RestoreBackup(backup, logger); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	if got := readTestFile(t, spec); got != "# Spec" {
+		t.Errorf("specs/x.md = %q, want it untouched", got)
+	}
+	if got := readTestFile(t, constitution); got != "# Constitution, amended" {
+		t.Errorf("memory/constitution.md = %q, want it untouched", got)
+	}
+	if got := readTestFile(t, filepath.Join(paths.SpecifyDir, "templates", "spec.md")); got != "spec" {
+		t.Errorf("restored spec = %q, want %q", got, "spec")
+	}
+}
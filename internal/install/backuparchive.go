@@ -0,0 +1,417 @@
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// archiveFilesDir is the directory of an exported backup that holds its files,
+// laid out as in a backup directory, next to the backup metadata in
+// backup.json
+const archiveFilesDir = "files"
+
+// ExportOptions contains backup export configuration
+type ExportOptions struct {
+	Wait time.Duration // How long to wait for another operation on the installation to finish
+}
+
+// ImportOptions contains backup import configuration
+type ImportOptions struct {
+	Wait time.Duration // How long to wait for another operation on the installation to finish
+}
+
+// ExportBackup writes a backup to a gzip-compressed tar archive that
+// ImportBackup can add to an installation on another machine. The backup is
+// verified first, and a corrupt one is not exported.
+func ExportBackup(backup *BackupInfo, archivePath string, opts ExportOptions, logger Logger) error {
+	paths, err := GetPaths(backup.OriginalPath)
+	if err != nil {
+		return fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	// Keep a prune from removing the backup or its objects while they are read
+	opLock, err := AcquireOperationLock(paths, "export", opts.Wait, logger)
+	if err != nil {
+		return err
+	}
+	defer opLock.Release()
+
+	verification, err := VerifyBackup(backup)
+	switch {
+	case errors.Is(err, ErrNoBackupMetadata):
+		logger.Warn("backup", "Backup %s has no metadata and cannot be verified", backup.BackupID)
+	case err != nil:
+		return fmt.Errorf("failed to verify backup: %w", err)
+	default:
+		if err := verification.Err(); err != nil {
+			return err
+		}
+	}
+
+	// Get the files of the backup as a directory
+	dir := backup.BackupPath
+	var metadata *BackupMetadata
+	if backup.Storage == BackupStorageStore {
+		if metadata, err = readManifest(backup.BackupPath); err != nil {
+			return err
+		}
+		if dir, err = newBackupStore(backup.OriginalPath).extractTemp(metadata); err != nil {
+			return fmt.Errorf("failed to extract backup: %w", err)
+		}
+		defer os.RemoveAll(dir)
+	} else if metadata, err = directoryBackupMetadata(backup, logger); err != nil {
+		return err
+	}
+
+	// Let the importing side relocate the paths in the version lock
+	exported := *metadata
+	exported.Storage = ""
+	if exported.ClaudeDir == "" {
+		exported.ClaudeDir = paths.ClaudeDir
+	}
+	limitToOwnedFiles(&exported, paths)
+
+	logger.Info("backup", "Exporting backup %s to %s...", backup.BackupID, archivePath)
+	if err := writeBackupArchive(archivePath, &exported, dir); err != nil {
+		return fmt.Errorf("failed to export backup: %w", err)
+	}
+
+	logger.Success("backup", "Backup exported: %s", archivePath)
+	return nil
+}
+
+// directoryBackupMetadata returns the metadata of a backup directory, made
+// up from its content if it has no backup.json
+func directoryBackupMetadata(backup *BackupInfo, logger Logger) (*BackupMetadata, error) {
+	metadata, err := readBackupMetadata(backup.BackupPath)
+	if err == nil {
+		return metadata, nil
+	}
+	if !errors.Is(err, ErrNoBackupMetadata) {
+		return nil, err
+	}
+
+	files, err := hashBackupFiles(backup.BackupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup %s: %w", backup.BackupID, err)
+	}
+	metadata = &BackupMetadata{
+		BackupID:     backup.BackupID,
+		CreatedAt:    backup.CreatedAt,
+		OriginalPath: backup.OriginalPath,
+		Files:        files,
+	}
+	for _, file := range files {
+		metadata.Size += file.Size
+	}
+	if err := recordVersions(metadata, backup.BackupPath, logger); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// limitToOwnedFiles drops the files and directories of a backup that lie
+// outside the entries the installation owns. Backups made by earlier releases
+// hold the whole installation directory, which may be a project with its own
+// files and secrets that must not end up in an archive meant to be shared.
+func limitToOwnedFiles(metadata *BackupMetadata, paths *InstallationPaths) {
+	owned := map[string]bool{backupClaudeDir: true}
+	for _, name := range ownedInstallEntries(paths) {
+		owned[name] = true
+	}
+	isOwned := func(rel string) bool {
+		top, _, _ := strings.Cut(rel, "/")
+		return owned[top]
+	}
+
+	dirs := []string{}
+	for _, dir := range metadata.Dirs {
+		if isOwned(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	files := []BackupFile{}
+	metadata.Size = 0
+	for _, file := range metadata.Files {
+		if isOwned(file.Path) {
+			files = append(files, file)
+			metadata.Size += file.Size
+		}
+	}
+	metadata.Dirs = dirs
+	metadata.Files = files
+}
+
+// writeBackupArchive writes the metadata and the directories and files it
+// lists below dir to a .tar.gz archive. The archive is renamed into place
+// once it is complete.
+func writeBackupArchive(archivePath string, metadata *BackupMetadata, dir string) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup metadata: %w", err)
+	}
+
+	if err := config.EnsureDir(filepath.Dir(archivePath)); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(zw)
+	now := time.Now().UTC()
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     backupMetadataFile,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  now,
+		Typeflag: tar.TypeReg,
+	})
+	if err == nil {
+		_, err = tw.Write(data)
+	}
+	for _, rel := range metadata.Dirs {
+		if err != nil {
+			break
+		}
+		err = addArchiveEntry(tw, filepath.Join(dir, filepath.FromSlash(rel)), archiveFilesDir+"/"+rel)
+	}
+	for _, file := range metadata.Files {
+		if err != nil {
+			break
+		}
+		err = addArchiveEntry(tw, filepath.Join(dir, filepath.FromSlash(file.Path)), archiveFilesDir+"/"+file.Path)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), archivePath)
+}
+
+// addArchiveEntry writes a file or directory to a tar archive
+func addArchiveEntry(tw *tar.Writer, path, name string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+	}
+	if info.IsDir() {
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+		return tw.WriteHeader(header)
+	}
+	header.Typeflag = tar.TypeReg
+	header.Size = info.Size()
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// ImportBackup adds a backup exported with ExportBackup to the backups of the
+// installation at prefix, which does not need to exist yet. The files are
+// checked against the exported metadata, and the paths in the backed-up
+// version lock are moved to this installation and Claude directory. Restore
+// the imported backup with rollback.
+func ImportBackup(prefix, archivePath string, opts ImportOptions, logger Logger) (*BackupInfo, error) {
+	installPath, err := config.ToAbsolutePath(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve installation path: %w", err)
+	}
+	paths, err := GetPaths(installPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	// Keep a prune from removing objects the import relies on
+	opLock, err := AcquireOperationLock(paths, "import", opts.Wait, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer opLock.Release()
+
+	logger.Info("backup", "Importing backup from %s...", archivePath)
+
+	store := newBackupStore(installPath)
+	if err := config.EnsureDir(store.dir); err != nil {
+		return nil, fmt.Errorf("failed to create backup store: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(store.dir, storeRestorePrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	extractDir := filepath.Join(tmpDir, "archive")
+	if err := ExtractArchive(archivePath, extractDir); err != nil {
+		return nil, err
+	}
+
+	exported, err := readBackupMetadata(extractDir)
+	if errors.Is(err, ErrNoBackupMetadata) {
+		return nil, fmt.Errorf("%s is not an exported backup: %w", archivePath, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Refuse an archive that was damaged or changed after the export
+	filesDir := filepath.Join(extractDir, archiveFilesDir)
+	actual, err := hashBackupFiles(filesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+	verification := &BackupVerification{BackupID: exported.BackupID}
+	compareBackupFiles(verification, exported.Files, actual)
+	if err := verification.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := relocateBackup(filesDir, exported, installPath, paths.ClaudeDir, logger); err != nil {
+		return nil, fmt.Errorf("failed to import backup: %w", err)
+	}
+
+	metadata := &BackupMetadata{
+		BackupID:       store.uniqueBackupID(installPath, exported.BackupID),
+		CreatedAt:      exported.CreatedAt,
+		OriginalPath:   installPath,
+		InstallationID: exported.InstallationID,
+		Versions:       exported.Versions,
+		Trigger:        exported.Trigger,
+		Label:          exported.Label,
+		OperationID:    exported.OperationID,
+		Storage:        BackupStorageStore,
+		ClaudeDir:      paths.ClaudeDir,
+		Files:          []BackupFile{},
+	}
+	if _, err := store.addTree(metadata, filesDir, ""); err != nil {
+		store.collectGarbage(logger)
+		return nil, fmt.Errorf("failed to import backup: %w", err)
+	}
+	for _, file := range metadata.Files {
+		metadata.Size += file.Size
+	}
+	if err := store.writeManifest(metadata); err != nil {
+		store.collectGarbage(logger)
+		return nil, err
+	}
+
+	logger.Success("backup", "Backup imported: %s", metadata.BackupID)
+	return backupInfoFromMetadata(metadata, store.manifestPath(metadata.BackupID)), nil
+}
+
+// relocateBackup rewrites the paths in the version lock of an exported backup
+// from the installation and Claude directory it was made of to those it is
+// imported into
+func relocateBackup(dir string, exported *BackupMetadata, installPath, claudeDir string, logger Logger) error {
+	moves := map[string]string{}
+	if exported.OriginalPath != "" && exported.OriginalPath != installPath {
+		moves[exported.OriginalPath] = installPath
+	}
+	if exported.ClaudeDir != "" && exported.ClaudeDir != claudeDir {
+		moves[exported.ClaudeDir] = claudeDir
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+
+	lockPath, err := config.GetVersionLockPath(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{lockPath, models.VersionLockBackupPath(lockPath)} {
+		if !config.PathExists(path) {
+			continue
+		}
+		logger.Debug("backup", "Relocating %s", filepath.Base(path))
+		if err := relocateVersionLock(path, moves); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relocateVersionLock rewrites the paths in a version lock file that lie in
+// one of the directories of moves to the directory it maps to
+func relocateVersionLock(path string, moves map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var lock models.VersionLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	// Longest first, in case one directory is inside the other
+	var from []string
+	for dir := range moves {
+		from = append(from, dir)
+	}
+	sort.Slice(from, func(i, j int) bool { return len(from[i]) > len(from[j]) })
+	relocate := func(p string) string {
+		for _, dir := range from {
+			if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+				return moves[dir] + p[len(dir):]
+			}
+		}
+		return p
+	}
+
+	for i := range lock.Files {
+		file := &lock.Files[i]
+		file.Path = relocate(file.Path)
+		// Releases before sources were recorded relative to the source tree
+		// recorded commands by their installed template
+		if filepath.IsAbs(filepath.FromSlash(file.Source)) {
+			file.Source = filepath.ToSlash(relocate(filepath.FromSlash(file.Source)))
+		}
+	}
+	for name, comp := range lock.Components {
+		comp.InstallPath = relocate(comp.InstallPath)
+		lock.Components[name] = comp
+	}
+
+	data, err = json.MarshalIndent(&lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
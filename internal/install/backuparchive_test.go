@@ -0,0 +1,218 @@
+package install
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+func TestExportImportBackup(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	opts := BackupOptions{Label: "before experiment"}
+	backup, err := BackupInstallation(paths.Prefix, opts, logger)
+	if err != nil {
+		t.Fatalf("BackupInstallation() error = %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "known-good.tar.gz")
+	if err := ExportBackup(backup, archive, ExportOptions{}, logger); err != nil {
+		t.Fatalf("ExportBackup() error = %v", err)
+	}
+
+	// Import on another machine, with another home and prefix
+	target, _ := setupFakeInstallation(t)
+	imported, err := ImportBackup(target.Prefix, archive, ImportOptions{}, logger)
+	if err != nil {
+		t.Fatalf("ImportBackup() error = %v", err)
+	}
+	if imported.BackupID != backup.BackupID || imported.Label != "before experiment" {
+		t.Errorf("ImportBackup() = %s %q, want %s %q", imported.BackupID, imported.Label, backup.BackupID, "before experiment")
+	}
+
+	backups, err := ListBackups(target.Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].Label != "before experiment" {
+		t.Fatalf("ListBackups() = %v, want the imported backup with its label", backups)
+	}
+
+	// The owned files now belong to the new installation and home
+	lock, err := version.LoadVersionLockFromPath(filepath.Join(extractTestBackup(t, imported), ".version-lock.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Files) == 0 {
+		t.Fatal("imported version lock has no owned files")
+	}
+	for _, file := range lock.Files {
+		if !strings.HasPrefix(file.Path, target.Prefix) && !strings.HasPrefix(file.Path, target.ClaudeDir) {
+			t.Errorf("owned file %s was not relocated", file.Path)
+		}
+	}
+
+	// Importing again keeps both
+	again, err := ImportBackup(target.Prefix, archive, ImportOptions{}, logger)
+	if err != nil {
+		t.Fatalf("ImportBackup() error = %v", err)
+	}
+	if again.BackupID == imported.BackupID {
+		t.Errorf("second import reused backup ID %s", again.BackupID)
+	}
+
+	writeTestFile(t, filepath.Join(target.ClaudeAgents, "cat-documentation.md"), "changed")
+	if _, err := Rollback(target.Prefix, RollbackOptions{BackupID: imported.BackupID}, logger); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(target.ClaudeAgents, "cat-documentation.md")); got != "agent" {
+		t.Errorf("rolled back agent = %q, want %q", got, "agent")
+	}
+}
+
+func TestImportBackup_Tampered(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	backup, err := BackupInstallation(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("BackupInstallation() error = %v", err)
+	}
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := ExportBackup(backup, archive, ExportOptions{}, logger); err != nil {
+		t.Fatalf("ExportBackup() error = %v", err)
+	}
+
+	// Change a file and pack the archive again with the original metadata
+	dir := t.TempDir()
+	if err := ExtractArchive(archive, dir); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := readBackupMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, archiveFilesDir, ".specify", "templates", "spec.md"), "tampered")
+	if err := writeBackupArchive(archive, metadata, filepath.Join(dir, archiveFilesDir)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportBackup(paths.Prefix, archive, ImportOptions{}, logger); !errors.Is(err, ErrBackupCorrupt) {
+		t.Fatalf("ImportBackup() error = %v, want ErrBackupCorrupt", err)
+	}
+	backups, err := ListBackups(paths.Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("ListBackups() has %d backups after a refused import, want 1", len(backups))
+	}
+}
+
+func TestExportBackup_Locked(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	backup, err := BackupInstallation(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("BackupInstallation() error = %v", err)
+	}
+
+	// A prune holding the lock could remove the backup while it is read
+	lock, err := AcquireOperationLock(paths, "prune", 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := ExportBackup(backup, archive, ExportOptions{}, logger); !errors.Is(err, ErrLocked) {
+		t.Errorf("ExportBackup() error = %v, want ErrLocked", err)
+	}
+}
+
+func TestExportBackup_OnlyOwnedFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	// An installation in a project directory, backed up in full as releases
+	// before backups were limited to owned files did
+	writeTestFile(t, filepath.Join(paths.Prefix, ".env"), "TOKEN=secret")
+	writeTestFile(t, filepath.Join(paths.Prefix, "src", "main.go"), "package main")
+	backups := []*BackupInfo{createDirectoryBackup(t, paths)}
+
+	backup, err := BackupInstallation(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("BackupInstallation() error = %v", err)
+	}
+	backups = append(backups, backup)
+
+	for _, backup := range backups {
+		archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+		if err := ExportBackup(backup, archive, ExportOptions{}, logger); err != nil {
+			t.Fatalf("ExportBackup(%s) error = %v", backup.Storage, err)
+		}
+		dir := t.TempDir()
+		if err := ExtractArchive(archive, dir); err != nil {
+			t.Fatal(err)
+		}
+
+		files := filepath.Join(dir, archiveFilesDir)
+		for _, name := range []string{".env", "src"} {
+			if config.PathExists(filepath.Join(files, name)) {
+				t.Errorf("ExportBackup(%s) exported %s, which the installation does not own", backup.Storage, name)
+			}
+		}
+		if !config.PathExists(filepath.Join(files, ".specify", "templates", "spec.md")) {
+			t.Errorf("ExportBackup(%s) left out an owned file", backup.Storage)
+		}
+		metadata, err := readBackupMetadata(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range metadata.Files {
+			if file.Path == ".env" || strings.HasPrefix(file.Path, "src/") {
+				t.Errorf("ExportBackup(%s) metadata lists %s", backup.Storage, file.Path)
+			}
+		}
+	}
+}
+
+func TestRelocateVersionLock(t *testing.T) {
+	from := filepath.Join(t.TempDir(), "old")
+	to := filepath.Join(t.TempDir(), "new")
+
+	lock := version.CreateVersionLock("2.0.0", "0.0.72", from)
+	lock.AddFiles([]models.OwnedFile{
+		{Path: filepath.Join(from, ".specify", "templates", "spec.md"), SHA256: strings.Repeat("1", 64), Source: ".specify/templates/spec.md"},
+		// Commands recorded by their installed template, as older releases did
+		{Path: filepath.Join(from, "speckit.plan.md"), SHA256: strings.Repeat("2", 64),
+			Source: filepath.ToSlash(filepath.Join(from, ".specify", "templates", "commands", "plan.md"))},
+	})
+	lockPath := filepath.Join(t.TempDir(), ".version-lock.json")
+	if err := version.SaveVersionLock(lock, lockPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := relocateVersionLock(lockPath, map[string]string{from: to}); err != nil {
+		t.Fatalf("relocateVersionLock() error = %v", err)
+	}
+
+	relocated, err := version.LoadVersionLockFromPath(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.OwnedFile{
+		{Path: filepath.Join(to, ".specify", "templates", "spec.md"), Source: ".specify/templates/spec.md"},
+		{Path: filepath.Join(to, "speckit.plan.md"), Source: filepath.ToSlash(filepath.Join(to, ".specify", "templates", "commands", "plan.md"))},
+	}
+	for i, file := range relocated.Files {
+		if file.Path != want[i].Path || file.Source != want[i].Source {
+			t.Errorf("file %d = %s from %s, want %s from %s", i, file.Path, file.Source, want[i].Path, want[i].Source)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+)
+
+// backupMetadataFile is the name of the metadata file in a directory backup
+const backupMetadataFile = "backup.json"
+
+// ErrNoBackupMetadata is returned for directory backups made before backups
+// carried metadata, which therefore cannot be verified
+var ErrNoBackupMetadata = errors.New("backup has no metadata")
+
+// BackupMetadata describes a backup. It is the manifest of a backup in the
+// backup store, and backup.json in a directory backup.
+type BackupMetadata struct {
+	BackupID       string            `json:"backup_id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	OriginalPath   string            `json:"original_path"`
+	InstallationID string            `json:"installation_id,omitempty"`
+	Versions       map[string]string `json:"versions,omitempty"` // Component name to version
+	Trigger        string            `json:"trigger,omitempty"`  // Command that made the backup
+	Label          string            `json:"label,omitempty"`
+	OperationID    string            `json:"operation_id,omitempty"`
+	Storage        string            `json:"storage,omitempty"`    // Empty for directory backups
+	ClaudeDir      string            `json:"claude_dir,omitempty"` // Where the owned Claude Code files were
+	Size           int64             `json:"size"`                 // Total size of the files, uncompressed
+	Dirs           []string          `json:"dirs,omitempty"`
+	Files          []BackupFile      `json:"files"`
+}
+
+// BackupFile is a file in a backup, relative to the installation with forward
+// slashes
+type BackupFile struct {
+	Path   string      `json:"path"`
+	Size   int64       `json:"size"`
+	SHA256 string      `json:"sha256"`
+	Mode   os.FileMode `json:"mode,omitempty"`
+}
+
+// BackupVerification is the result of checking a backup against its metadata
+type BackupVerification struct {
+	BackupID string   `json:"backup_id"`
+	Checked  int      `json:"checked"`
+	Modified []string `json:"modified"`
+	Missing  []string `json:"missing"`
+	Extra    []string `json:"extra"`
+}
+
+// Err returns an error wrapping ErrBackupCorrupt if the backup does not match
+// its metadata, or nil
+func (v *BackupVerification) Err() error {
+	if len(v.Modified) == 0 && len(v.Missing) == 0 && len(v.Extra) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: backup %s has %d modified, %d missing, %d extra file(s)",
+		ErrBackupCorrupt, v.BackupID, len(v.Modified), len(v.Missing), len(v.Extra))
+}
+
+// recordVersions records the installation ID and component versions of the
+// installation at installPath in the backup metadata
+func recordVersions(metadata *BackupMetadata, installPath string, logger Logger) error {
+	lockPath, err := config.GetVersionLockPath(installPath)
+	if err != nil {
+		return err
+	}
+
+	lock, err := version.LoadVersionLockFromPath(lockPath)
+	if err != nil {
+		if config.PathExists(lockPath) {
+			logger.Warn("backup", "Failed to read the version lock: %v", err)
+		}
+		return nil
+	}
+
+	metadata.InstallationID = lock.InstallationID
+	metadata.Versions = map[string]string{}
+	for name, comp := range lock.Components {
+		metadata.Versions[name] = comp.Version
+	}
+	return nil
+}
+
+// loadBackupMetadata reads the metadata of a backup in either storage format
+func loadBackupMetadata(backup *BackupInfo) (*BackupMetadata, error) {
+	if backup.Storage == BackupStorageStore {
+		return readManifest(backup.BackupPath)
+	}
+	return readBackupMetadata(backup.BackupPath)
+}
+
+// readBackupMetadata reads the metadata of a backup
+func readBackupMetadata(backupPath string) (*BackupMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(backupPath, backupMetadataFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoBackupMetadata
+		}
+		return nil, fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+
+	var metadata BackupMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %v", ErrBackupCorrupt, backupMetadataFile, err)
+	}
+	return &metadata, nil
+}
+
+// hashBackupFiles lists and hashes the files of a directory backup, apart
+// from its metadata, in path order
+func hashBackupFiles(backupPath string) ([]BackupFile, error) {
+	paths, err := listFilesRecursive(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []BackupFile{}
+	for _, path := range paths {
+		rel, err := filepath.Rel(backupPath, path)
+		if err != nil {
+			return nil, err
+		}
+		if rel == backupMetadataFile {
+			continue
+		}
+		hash, size, err := HashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, BackupFile{Path: filepath.ToSlash(rel), Size: size, SHA256: hash})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// VerifyBackup checks that a backup still holds exactly the files recorded in
+// its metadata. For a backup in the backup store, every object it refers to
+// must exist and hold the recorded content. Directory backups without
+// metadata return ErrNoBackupMetadata.
+func VerifyBackup(backup *BackupInfo) (*BackupVerification, error) {
+	if !config.PathExists(backup.BackupPath) {
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, backup.BackupID)
+	}
+
+	metadata, err := loadBackupMetadata(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &BackupVerification{
+		BackupID: backup.BackupID,
+		Modified: []string{},
+		Missing:  []string{},
+		Extra:    []string{},
+	}
+
+	if backup.Storage == BackupStorageStore {
+		store := newBackupStore(backup.OriginalPath)
+		for _, file := range metadata.Files {
+			verification.Checked++
+			switch exists, intact := store.checkObject(file.SHA256); {
+			case !exists:
+				verification.Missing = append(verification.Missing, file.Path)
+			case !intact:
+				verification.Modified = append(verification.Modified, file.Path)
+			}
+		}
+		return verification, nil
+	}
+
+	actual, err := hashBackupFiles(backup.BackupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup %s: %w", backup.BackupID, err)
+	}
+	compareBackupFiles(verification, metadata.Files, actual)
+
+	return verification, nil
+}
+
+// compareBackupFiles records in verification how the files found in a backup
+// differ from those expected
+func compareBackupFiles(verification *BackupVerification, expected, actual []BackupFile) {
+	actualByPath := make(map[string]BackupFile, len(actual))
+	for _, file := range actual {
+		actualByPath[file.Path] = file
+	}
+
+	for _, want := range expected {
+		verification.Checked++
+		file, ok := actualByPath[want.Path]
+		switch {
+		case !ok:
+			verification.Missing = append(verification.Missing, want.Path)
+		case file.SHA256 != want.SHA256:
+			verification.Modified = append(verification.Modified, want.Path)
+		}
+		delete(actualByPath, want.Path)
+	}
+	for _, file := range actual {
+		if _, extra := actualByPath[file.Path]; extra {
+			verification.Extra = append(verification.Extra, file.Path)
+		}
+	}
+}
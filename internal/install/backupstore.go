@@ -0,0 +1,436 @@
+package install
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// Storage formats of a backup
+const (
+	// BackupStorageDirectory is a full copy of the installation in a
+	// <prefix>.backup-<timestamp> directory, as made by older releases
+	BackupStorageDirectory = "directory"
+	// BackupStorageStore is a manifest in the backup store whose files are
+	// kept as shared, compressed objects
+	BackupStorageStore = "store"
+)
+
+// Layout of the backup store, <prefix>.backups next to the installation.
+// Objects are gzip-compressed file contents named after the SHA-256 of the
+// uncompressed content, so a file that is the same in several backups is
+// only stored once.
+const (
+	backupStoreSuffix  = ".backups"
+	storeObjectsDir    = "objects"
+	storeManifestsDir  = "manifests"
+	storeRestorePrefix = "restore-"
+)
+
+// backupStore is the content-addressed store holding the backups of an
+// installation
+type backupStore struct {
+	dir string
+}
+
+// newBackupStore returns the backup store of the installation at installPath,
+// which must be absolute
+func newBackupStore(installPath string) *backupStore {
+	return &backupStore{dir: installPath + backupStoreSuffix}
+}
+
+// objectPath returns the path of the object with the given hash
+func (s *backupStore) objectPath(hash string) string {
+	return filepath.Join(s.dir, storeObjectsDir, hash[:2], hash)
+}
+
+// manifestPath returns the path of the manifest of a backup
+func (s *backupStore) manifestPath(backupID string) string {
+	return filepath.Join(s.dir, storeManifestsDir, backupID+".json")
+}
+
+// uniqueBackupID returns id, or id with a number appended if a backup of the
+// installation at installPath already has it
+func (s *backupStore) uniqueBackupID(installPath, id string) string {
+	unique := id
+	for n := 2; config.PathExists(s.manifestPath(unique)) || config.PathExists(installPath+"."+unique); n++ {
+		unique = fmt.Sprintf("%s-%d", id, n)
+	}
+	return unique
+}
+
+// addFile stores the content of a file unless an object with the same hash
+// exists. It returns the entry for the backup manifest and whether a new
+// object was written.
+func (s *backupStore) addFile(file, rel string) (BackupFile, bool, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return BackupFile{}, false, err
+	}
+	hash, size, err := HashFile(file)
+	if err != nil {
+		return BackupFile{}, false, err
+	}
+	entry := BackupFile{Path: rel, Size: size, SHA256: hash, Mode: info.Mode().Perm()}
+
+	object := s.objectPath(hash)
+	if config.PathExists(object) {
+		return entry, false, nil
+	}
+	if err := writeObject(file, object); err != nil {
+		return BackupFile{}, false, fmt.Errorf("failed to store %s: %w", file, err)
+	}
+	return entry, true, nil
+}
+
+// writeObject compresses a file into an object, renaming it into place only
+// once it is complete
+func writeObject(file, object string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := config.EnsureDir(filepath.Dir(object)); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(object), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), object)
+}
+
+// addTree stores every file below dir, recording paths below prefix in the
+// manifest. Runtime files at the top of an installation are left out. It
+// returns the number of new objects written.
+func (s *backupStore) addTree(metadata *BackupMetadata, dir, prefix string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, entry := range entries {
+		if prefix == "" && isRuntimeFile(entry.Name()) {
+			continue
+		}
+		rel := path.Join(prefix, entry.Name())
+		full := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			metadata.Dirs = append(metadata.Dirs, rel)
+			n, err := s.addTree(metadata, full, rel)
+			if err != nil {
+				return 0, err
+			}
+			added += n
+			continue
+		}
+
+		file, isNew, err := s.addFile(full, rel)
+		if err != nil {
+			return 0, err
+		}
+		metadata.Files = append(metadata.Files, file)
+		if isNew {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// openObject returns a reader for the uncompressed content of an object
+func (s *backupStore) openObject(hash string) (io.ReadCloser, error) {
+	file, err := os.Open(s.objectPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &objectReader{Reader: zr, file: file}, nil
+}
+
+// objectReader closes the object file along with the decompressor
+type objectReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *objectReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// checkObject reports whether an object exists and holds the content with
+// the given hash
+func (s *backupStore) checkObject(hash string) (exists, intact bool) {
+	r, err := s.openObject(hash)
+	if os.IsNotExist(err) {
+		return false, false
+	}
+	if err != nil {
+		return true, false
+	}
+	defer r.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return true, false
+	}
+	return true, hex.EncodeToString(hasher.Sum(nil)) == hash
+}
+
+// extract writes the files of a backup below dir
+func (s *backupStore) extract(metadata *BackupMetadata, dir string) error {
+	for _, rel := range metadata.Dirs {
+		if err := config.EnsureDir(filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range metadata.Files {
+		if err := s.extractFile(file, filepath.Join(dir, filepath.FromSlash(file.Path))); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// extractFile writes one file of a backup to dst
+func (s *backupStore) extractFile(file BackupFile, dst string) error {
+	r, err := s.openObject(file.SHA256)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := config.EnsureDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	mode := file.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+// extractTemp extracts a backup into a new directory in the store, which the
+// caller removes
+func (s *backupStore) extractTemp(metadata *BackupMetadata) (string, error) {
+	if err := config.EnsureDir(s.dir); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(s.dir, storeRestorePrefix)
+	if err != nil {
+		return "", err
+	}
+	if err := s.extract(metadata, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// writeManifest records a backup in the store
+func (s *backupStore) writeManifest(metadata *BackupMetadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+	if err := config.EnsureDir(filepath.Join(s.dir, storeManifestsDir)); err != nil {
+		return fmt.Errorf("failed to create backup store: %w", err)
+	}
+	if err := models.WriteFileAtomic(s.manifestPath(metadata.BackupID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+// readManifest reads a backup manifest
+func readManifest(manifestPath string) (*BackupMetadata, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, manifestPath)
+		}
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+
+	var metadata BackupMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest %s: %v", ErrBackupCorrupt, manifestPath, err)
+	}
+	return &metadata, nil
+}
+
+// manifestPaths returns the manifest files in the store, sorted by name
+func (s *backupStore) manifestPaths() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, storeManifestsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifests []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		manifests = append(manifests, filepath.Join(s.dir, storeManifestsDir, entry.Name()))
+	}
+	sort.Strings(manifests)
+	return manifests, nil
+}
+
+// objectSizes returns the stored size of each object a manifest refers to
+func (s *backupStore) objectSizes(manifestPath string) (map[string]int64, error) {
+	metadata, err := readManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(metadata.Files))
+	for _, file := range metadata.Files {
+		var size int64 // A missing object takes up no space
+		if info, err := os.Stat(s.objectPath(file.SHA256)); err == nil {
+			size = info.Size()
+		}
+		sizes[file.SHA256] = size
+	}
+	return sizes, nil
+}
+
+// exclusiveSize returns the stored size of the objects that the manifests in
+// remove refer to and the manifests in keep do not, which is what removing
+// those backups frees
+func (s *backupStore) exclusiveSize(remove, keep []string) (int64, error) {
+	kept := map[string]bool{}
+	for _, manifestPath := range keep {
+		metadata, err := readManifest(manifestPath)
+		if err != nil {
+			return 0, err
+		}
+		for _, file := range metadata.Files {
+			kept[file.SHA256] = true
+		}
+	}
+
+	var size int64
+	counted := map[string]bool{}
+	for _, manifestPath := range remove {
+		metadata, err := readManifest(manifestPath)
+		if err != nil {
+			continue
+		}
+		for _, file := range metadata.Files {
+			if kept[file.SHA256] || counted[file.SHA256] {
+				continue
+			}
+			counted[file.SHA256] = true
+			if info, err := os.Stat(s.objectPath(file.SHA256)); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return size, nil
+}
+
+// collectGarbage removes the objects that no manifest refers to, and
+// directories left behind by interrupted restores. It returns the number of
+// bytes freed. Nothing is removed if a manifest cannot be read, as its
+// objects would be lost.
+func (s *backupStore) collectGarbage(logger Logger) (int64, error) {
+	manifests, err := s.manifestPaths()
+	if err != nil {
+		return 0, err
+	}
+
+	referenced := map[string]bool{}
+	for _, manifestPath := range manifests {
+		metadata, err := readManifest(manifestPath)
+		if err != nil {
+			return 0, fmt.Errorf("not removing unused backup objects: %w", err)
+		}
+		for _, file := range metadata.Files {
+			referenced[file.SHA256] = true
+		}
+	}
+
+	var freed int64
+	objects, err := listFilesRecursive(filepath.Join(s.dir, storeObjectsDir))
+	if err != nil {
+		return 0, err
+	}
+	for _, object := range objects {
+		if referenced[filepath.Base(object)] {
+			continue
+		}
+		info, err := os.Stat(object)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(object); err != nil {
+			return freed, err
+		}
+		freed += info.Size()
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return freed, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), storeRestorePrefix) {
+			os.RemoveAll(filepath.Join(s.dir, entry.Name()))
+		}
+	}
+
+	if freed > 0 {
+		logger.Debug("backup", "Removed unused backup objects (%s)", FormatSize(freed))
+	}
+	return freed, nil
+}
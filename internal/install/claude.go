@@ -2,8 +2,10 @@ package install
 
 import (
 	"fmt"
+	"io/fs"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
 )
 
 // SetupClaudeDirectory creates the .claude/ directory structure for Claude Code integration
@@ -46,8 +48,11 @@ func SetupClaudeDirectory() error {
 	return nil
 }
 
-// IntegrateWithClaude copies agents and commands to .claude/ directories
-func IntegrateWithClaude(paths *InstallationPaths) (*ClaudeIntegrationResult, error) {
+// IntegrateWithClaude copies agents from the source tree and commands from the
+// spec-kit templates to .claude/ directories. The commands are read from the
+// spec-kit files being installed, not from the installed templates, which
+// during an update still hold commands the new release no longer ships.
+func IntegrateWithClaude(paths *InstallationPaths, source *Source, specKit SourceDir) (*ClaudeIntegrationResult, error) {
 	result := &ClaudeIntegrationResult{}
 
 	// Ensure .claude/ structure exists
@@ -56,31 +61,28 @@ func IntegrateWithClaude(paths *InstallationPaths) (*ClaudeIntegrationResult, er
 	}
 
 	// Copy agents with "cat-" prefix
-	if config.IsDirectory(paths.AgentsSourceDir) {
-		if err := CopyAgentsWithPrefix(paths.AgentsSourceDir, paths.ClaudeAgents); err != nil {
-			return nil, fmt.Errorf("failed to copy agents: %w", err)
-		}
-
-		// Count copied agents
-		agentCount, err := CountFiles(paths.ClaudeAgents)
+	if agents, err := source.Dir(paths.AgentsSourceDir); err == nil {
+		files, err := CopyAgentsWithPrefix(agents, paths.ClaudeAgents)
 		if err != nil {
-			return nil, fmt.Errorf("failed to count agents: %w", err)
+			return nil, fmt.Errorf("failed to copy agents: %w", err)
 		}
-		result.AgentsCopied = agentCount
+		result.Files = append(result.Files, files...)
+		result.AgentsCopied = countDistinctPaths(files)
 	}
 
 	// Copy spec-kit commands with "speckit." prefix
-	if config.IsDirectory(paths.TemplatesDir) {
-		if err := CopyCommandsWithPrefix(paths.TemplatesDir, paths.ClaudeCommands); err != nil {
-			return nil, fmt.Errorf("failed to copy commands: %w", err)
+	if info, err := fs.Stat(specKit.FS, "templates"); err == nil && info.IsDir() {
+		sub, err := fs.Sub(specKit.FS, "templates")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spec-kit templates: %w", err)
 		}
-
-		// Count copied commands
-		commandCount, err := CountFiles(paths.ClaudeCommands)
+		templates := SourceDir{FS: sub, Name: specKit.sourceName("templates")}
+		files, err := CopyCommandsWithPrefix(templates, paths.ClaudeCommands)
 		if err != nil {
-			return nil, fmt.Errorf("failed to count commands: %w", err)
+			return nil, fmt.Errorf("failed to copy commands: %w", err)
 		}
-		result.CommandsCopied = commandCount
+		result.Files = append(result.Files, files...)
+		result.CommandsCopied = countDistinctPaths(files)
 	}
 
 	result.Success = true
@@ -89,9 +91,20 @@ func IntegrateWithClaude(paths *InstallationPaths) (*ClaudeIntegrationResult, er
 
 // ClaudeIntegrationResult contains the results of Claude Code integration
 type ClaudeIntegrationResult struct {
-	Success        bool
-	AgentsCopied   int
-	CommandsCopied int
+	Success        bool               `json:"success"`
+	AgentsCopied   int                `json:"agents_copied"`
+	CommandsCopied int                `json:"commands_copied"`
+	Files          []models.OwnedFile `json:"files"`
+}
+
+// countDistinctPaths counts destination paths, since agents with the same
+// name in different source directories are written to the same file
+func countDistinctPaths(files []models.OwnedFile) int {
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		seen[file.Path] = true
+	}
+	return len(seen)
 }
 
 // GetSummary returns a human-readable summary of the integration
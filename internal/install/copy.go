@@ -1,13 +1,21 @@
 package install
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
 )
 
 // CopyFile copies a single file from src to dst
@@ -18,6 +26,14 @@ func CopyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 
+	// Installing into the source tree itself copies files onto themselves;
+	// creating the destination would truncate the source
+	if srcInfo, err := sourceFile.Stat(); err == nil {
+		if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(srcInfo, dstInfo) {
+			return nil
+		}
+	}
+
 	// Ensure destination directory exists
 	destDir := filepath.Dir(dst)
 	if err := config.EnsureDir(destDir); err != nil {
@@ -48,6 +64,110 @@ func CopyFile(src, dst string) error {
 	return nil
 }
 
+// CopyFSFile copies a single file from a source filesystem to dst
+func CopyFSFile(src fs.FS, name, dst string) error {
+	sourceFile, err := src.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open source file %s: %w", name, err)
+	}
+	defer sourceFile.Close()
+
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	// Installing into the source tree itself copies files onto themselves;
+	// creating the destination would truncate the source
+	if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(sourceInfo, dstInfo) {
+		return nil
+	}
+
+	content, err := io.ReadAll(sourceFile)
+	if err != nil {
+		return fmt.Errorf("failed to read source file %s: %w", name, err)
+	}
+
+	// Ensure destination directory exists
+	destDir := filepath.Dir(dst)
+	if err := config.EnsureDir(destDir); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	if err := os.WriteFile(dst, content, 0644); err != nil {
+		return fmt.Errorf("failed to create destination file %s: %w", dst, err)
+	}
+
+	// Copy permissions
+	if err := os.Chmod(dst, installMode(sourceInfo.Mode(), content)); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	return nil
+}
+
+// installMode returns the permissions for an installed file. The embedded
+// payload reports every file as read-only and keeps no executable bits, so
+// files from read-only sources are made writable and scripts executable.
+func installMode(mode fs.FileMode, content []byte) fs.FileMode {
+	perm := mode.Perm()
+	if perm&0222 != 0 {
+		return perm
+	}
+	if bytes.HasPrefix(content, []byte("#!")) {
+		return 0755
+	}
+	return 0644
+}
+
+// CopyOwnedFile copies a file of a source directory to dst and returns an
+// ownership record for the destination, suitable for the version lock
+func CopyOwnedFile(src SourceDir, name, dst string) (models.OwnedFile, error) {
+	if err := CopyFSFile(src.FS, name, dst); err != nil {
+		return models.OwnedFile{}, err
+	}
+
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return models.OwnedFile{}, fmt.Errorf("failed to resolve %s: %w", dst, err)
+	}
+
+	hash, size, err := HashFile(absDst)
+	if err != nil {
+		return models.OwnedFile{}, err
+	}
+
+	return models.OwnedFile{
+		Path:   absDst,
+		Size:   size,
+		SHA256: hash,
+		Source: src.sourceName(name),
+	}, nil
+}
+
+// HashFile returns the hex-encoded SHA-256 and size of a file
+func HashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// hashContent returns the hex-encoded SHA-256 of content
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
 // CopyDirectory recursively copies a directory from src to dst
 func CopyDirectory(src, dst string) error {
 	// Get source directory info
@@ -120,55 +240,61 @@ func CopyWithPrefix(srcDir, dstDir, prefix string) error {
 }
 
 // CopyAgentsWithPrefix copies agent files from source to .claude/agents/ with "cat-" prefix
-func CopyAgentsWithPrefix(agentsSourceDir, claudeAgentsDir string) error {
+// and returns an ownership record for each file written
+func CopyAgentsWithPrefix(agents SourceDir, claudeAgentsDir string) ([]models.OwnedFile, error) {
+	files := []models.OwnedFile{}
+
 	// Walk through all agent files (including subdirectories)
-	return filepath.Walk(agentsSourceDir, func(path string, info os.FileInfo, err error) error {
+	err := fs.WalkDir(agents.FS, ".", func(relPath string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip directories
-		if info.IsDir() {
+		if entry.IsDir() {
 			return nil
 		}
 
 		// Only copy .md files
-		if filepath.Ext(path) != ".md" {
+		if path.Ext(relPath) != ".md" {
 			return nil
 		}
 
-		// Get relative path from agents source dir
-		relPath, err := filepath.Rel(agentsSourceDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
-		}
-
 		// Create destination filename with "cat-" prefix
-		baseName := filepath.Base(relPath)
+		baseName := path.Base(relPath)
 		dstFileName := "cat-" + baseName
 		dstPath := filepath.Join(claudeAgentsDir, dstFileName)
 
 		// Copy file
-		if err := CopyFile(path, dstPath); err != nil {
+		file, err := CopyOwnedFile(agents, relPath, dstPath)
+		if err != nil {
 			return fmt.Errorf("failed to copy agent %s: %w", relPath, err)
 		}
+		files = append(files, file)
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
 }
 
 // CopyCommandsWithPrefix copies spec-kit commands to .claude/commands/ with "speckit." prefix
-func CopyCommandsWithPrefix(templatesDir, claudeCommandsDir string) error {
-	commandsDir := filepath.Join(templatesDir, "commands")
-	if !config.IsDirectory(commandsDir) {
+// and returns an ownership record for each file written
+func CopyCommandsWithPrefix(templates SourceDir, claudeCommandsDir string) ([]models.OwnedFile, error) {
+	files := []models.OwnedFile{}
+
+	if info, err := fs.Stat(templates.FS, "commands"); err != nil || !info.IsDir() {
 		// No commands directory, skip
-		return nil
+		return files, nil
 	}
 
 	// Read command files
-	entries, err := os.ReadDir(commandsDir)
+	entries, err := fs.ReadDir(templates.FS, "commands")
 	if err != nil {
-		return fmt.Errorf("failed to read commands directory: %w", err)
+		return nil, fmt.Errorf("failed to read commands directory: %w", err)
 	}
 
 	// Copy each command file with "speckit." prefix
@@ -178,38 +304,62 @@ func CopyCommandsWithPrefix(templatesDir, claudeCommandsDir string) error {
 		}
 
 		// Only copy .md files
-		if filepath.Ext(entry.Name()) != ".md" {
+		if path.Ext(entry.Name()) != ".md" {
 			continue
 		}
 
-		srcPath := filepath.Join(commandsDir, entry.Name())
+		srcPath := path.Join("commands", entry.Name())
 
 		// Create destination filename with "speckit." prefix
 		baseName := strings.TrimSuffix(entry.Name(), ".md")
 		dstFileName := "speckit." + baseName + ".md"
 		dstPath := filepath.Join(claudeCommandsDir, dstFileName)
 
-		if err := CopyFile(srcPath, dstPath); err != nil {
-			return fmt.Errorf("failed to copy command %s: %w", entry.Name(), err)
+		file, err := CopyOwnedFile(templates, srcPath, dstPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy command %s: %w", entry.Name(), err)
 		}
+		files = append(files, file)
 	}
 
-	return nil
+	return files, nil
 }
 
-// CopySpecKitFiles copies the .specify/ directory to the installation prefix
-func CopySpecKitFiles(srcSpecifyDir, dstSpecifyDir string) error {
+// CopySpecKitFiles copies the spec-kit files to the installation's .specify/
+// directory and returns an ownership record for each file written
+func CopySpecKitFiles(specKit SourceDir, dstSpecifyDir string) ([]models.OwnedFile, error) {
 	// Ensure source exists
-	if !config.IsDirectory(srcSpecifyDir) {
-		return fmt.Errorf("source .specify/ directory not found: %s", srcSpecifyDir)
+	if info, err := fs.Stat(specKit.FS, "."); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("source .specify/ directory not found: %s", specKit.Name)
 	}
 
+	files := []models.OwnedFile{}
+
 	// Copy entire .specify/ directory
-	if err := CopyDirectory(srcSpecifyDir, dstSpecifyDir); err != nil {
-		return fmt.Errorf("failed to copy .specify/ directory: %w", err)
+	err := fs.WalkDir(specKit.FS, ".", func(relPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		dstPath := filepath.Join(dstSpecifyDir, filepath.FromSlash(relPath))
+
+		if entry.IsDir() {
+			return config.EnsureDir(dstPath)
+		}
+
+		file, err := CopyOwnedFile(specKit, relPath, dstPath)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy .specify/ directory: %w", err)
 	}
 
-	return nil
+	return files, nil
 }
 
 // CountFiles counts files in a directory (non-recursive)
@@ -272,3 +422,37 @@ func FormatSize(bytes int64) string {
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
+
+// sizeUnits are the unit suffixes accepted by ParseSize, largest first so
+// that "MB" is not read as "B"
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40}, {"T", 1 << 40},
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "500MB", "1.5G" or "1024". Units are
+// powers of 1024, as in FormatSize, and are case-insensitive.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.Replace(value, "IB", "B", 1)
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid size %q (e.g. 500MB, 2GB)", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
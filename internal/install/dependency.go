@@ -0,0 +1,119 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// vendoredSpecKitDir is where the vendored spec-kit files live in the repository
+const vendoredSpecKitDir = ".specify"
+
+// specKitSourceName names the spec-kit tree in the sources recorded in the
+// version lock. Spec-kit files and the commands copied from its templates are
+// recorded relative to it whichever source they were fetched from, so that an
+// update reads them from the newly fetched tree.
+const specKitSourceName = ".specify"
+
+// DependencySource describes where the files of a dependency were obtained
+type DependencySource struct {
+	SourceDir        // Dependency files; named relative to the source tree when vendored
+	Source    string // Manifest source type (vendored, git, archive)
+	Commit    string // Resolved commit for git sources
+}
+
+// FetchDependency makes the files of a dependency available locally according
+// to its manifest source. Vendored dependencies are read from the source tree;
+// git dependencies are cloned and archives extracted into the cache below the
+// installation prefix. Relative archive paths are resolved against the source tree.
+func FetchDependency(name string, dep *models.Dependency, source *Source, paths *InstallationPaths, logger Logger) (*DependencySource, error) {
+	switch dep.Source {
+	case "vendored":
+		dir, err := source.Dir(vendoredSpecKitDir)
+		if err != nil {
+			return nil, err
+		}
+		return &DependencySource{
+			SourceDir: dir,
+			Source:    dep.Source,
+		}, nil
+
+	case "git":
+		cacheDir := filepath.Join(paths.CacheDir, "git", name)
+		commit, err := fetchGitDependency(dep.Repository, dep.Ref, cacheDir, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s from %s: %w", name, dep.Repository, err)
+		}
+
+		dir := cacheDir
+		if dep.Subdir != "" {
+			dir = filepath.Join(cacheDir, filepath.FromSlash(dep.Subdir))
+		}
+		if !config.IsDirectory(dir) {
+			return nil, fmt.Errorf("directory %s not found in %s at %s", dep.Subdir, dep.Repository, dep.Ref)
+		}
+
+		return &DependencySource{
+			SourceDir: DiskDir(dir),
+			Source:    dep.Source,
+			Commit:    commit,
+		}, nil
+
+	case "archive":
+		archive, err := archivePath(dep, source)
+		if err != nil {
+			return nil, err
+		}
+
+		// The archive is extracted in full on every install, so the cache
+		// never holds files from a previous archive
+		cacheDir := filepath.Join(paths.CacheDir, "archive", name)
+		logger.Debug("archive", "Extracting %s to %s", archive, cacheDir)
+		if err := ExtractArchive(archive, cacheDir); err != nil {
+			return nil, err
+		}
+
+		dir := cacheDir
+		if dep.Subdir != "" {
+			dir = filepath.Join(cacheDir, filepath.FromSlash(dep.Subdir))
+		}
+		if !config.IsDirectory(dir) {
+			return nil, fmt.Errorf("directory %s not found in %s", dep.Subdir, dep.Archive)
+		}
+
+		return &DependencySource{
+			SourceDir: DiskDir(dir),
+			Source:    dep.Source,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("dependency source %q is not supported by the installer", dep.Source)
+	}
+}
+
+// describeDependencySource describes where a dependency would be fetched
+// from, for dry runs
+func describeDependencySource(dep *models.Dependency) string {
+	switch dep.Source {
+	case "git":
+		return fmt.Sprintf("%s (%s)", dep.Repository, dep.Ref)
+	case "archive":
+		return dep.Archive
+	default:
+		return "the source tree (" + dep.Source + ")"
+	}
+}
+
+// archivePath returns the location of an archive dependency, resolving
+// relative paths against the source tree
+func archivePath(dep *models.Dependency, source *Source) (string, error) {
+	if filepath.IsAbs(dep.Archive) {
+		return dep.Archive, nil
+	}
+	if source.Root == "" {
+		return "", fmt.Errorf("archive %s is relative to the source tree, which is not on disk (use --source)", dep.Archive)
+	}
+	return filepath.Join(source.Root, filepath.FromSlash(dep.Archive)), nil
+}
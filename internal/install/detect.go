@@ -2,6 +2,7 @@ package install
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -22,6 +23,24 @@ func DetermineInstallPrefix() string {
 	return config.DetermineInstallPrefix()
 }
 
+// ResolvePrefix determines the installation prefix from the command-line
+// selection. A global installation always uses the default installation
+// directory (~/spec-kit-agents) and cannot be combined with a custom prefix.
+func ResolvePrefix(customPrefix string, global bool) (string, error) {
+	if global {
+		if customPrefix != "" {
+			return "", fmt.Errorf("--global and --prefix cannot be used together")
+		}
+		return config.GetDefaultInstallDir()
+	}
+
+	if customPrefix != "" {
+		return customPrefix, nil
+	}
+
+	return DetermineInstallPrefix(), nil
+}
+
 // DetectClaudeDir checks if .claude/ directory exists
 func DetectClaudeDir() (bool, string, error) {
 	claudeDir, err := config.GetClaudeDir()
@@ -46,7 +65,7 @@ func DetectExistingVersionLock(prefix string) (bool, string, error) {
 
 // DetectInstallationMode determines the installation mode based on environment
 type InstallationMode struct {
-	Mode        string // "fresh", "upgrade", "coexist"
+	Mode        string // "fresh", "upgrade", "coexist", "global"
 	HasSpecKit  bool
 	HasClaude   bool
 	HasLock     bool
@@ -171,6 +190,9 @@ type InstallationPaths struct {
 	SpecifyDir        string
 	AgentsSourceDir   string
 	TemplatesDir      string
+	PristineDir       string
+	CacheDir          string
+	TransactionDir    string
 }
 
 // GetPaths calculates all installation paths based on the prefix
@@ -223,14 +245,32 @@ func GetPaths(prefix string) (*InstallationPaths, error) {
 
 	// Spec-kit and source directories
 	paths.SpecifyDir = filepath.Join(prefix, ".specify")
-	paths.AgentsSourceDir = filepath.Join(prefix, "agents")
+	paths.AgentsSourceDir = "agents" // Relative to the source tree, see Source.Dir
 	paths.TemplatesDir = filepath.Join(prefix, ".specify", "templates")
 
+	// Pristine copies of installed files, used as merge base on update
+	paths.PristineDir = filepath.Join(absPrefix, ".pristine")
+
+	// Fetched dependency sources, kept for offline reinstalls
+	paths.CacheDir = filepath.Join(absPrefix, ".cache")
+
+	// Staging area and journal of an installation in progress
+	paths.TransactionDir = filepath.Join(absPrefix, ".transaction")
+
 	return paths, nil
 }
 
-// VerifySourceFiles checks that all required source files exist before installation
-func VerifySourceFiles() error {
+// VerifySourceFiles checks that all required source files exist in a source
+// tree before installation
+func VerifySourceFiles(root string) error {
+	if !config.IsDirectory(root) {
+		return fmt.Errorf("directory not found: %s", root)
+	}
+	return verifySourceFS(os.DirFS(root))
+}
+
+// verifySourceFS checks a source tree on disk or embedded in the binary
+func verifySourceFS(fsys fs.FS) error {
 	requiredDirs := []string{
 		".specify",
 		"agents",
@@ -241,13 +281,13 @@ func VerifySourceFiles() error {
 	}
 
 	for _, dir := range requiredDirs {
-		if !config.IsDirectory(dir) {
-			return fmt.Errorf("required directory not found: %s (are you running from repository root?)", dir)
+		if info, err := fs.Stat(fsys, dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("required directory not found: %s", dir)
 		}
 	}
 
 	for _, file := range requiredFiles {
-		if !config.PathExists(file) {
+		if _, err := fs.Stat(fsys, file); err != nil {
 			return fmt.Errorf("required file not found: %s", file)
 		}
 	}
@@ -0,0 +1,66 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePrefix(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := []struct {
+		name         string
+		customPrefix string
+		global       bool
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:         "custom prefix",
+			customPrefix: "/opt/agents",
+			want:         "/opt/agents",
+		},
+		{
+			name:   "global installation",
+			global: true,
+			want:   filepath.Join(home, "spec-kit-agents"),
+		},
+		{
+			name:         "global with custom prefix",
+			customPrefix: "/opt/agents",
+			global:       true,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolvePrefix(tt.customPrefix, tt.global)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResolvePrefix() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ResolvePrefix() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePrefix_AutoDetect(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if got, _ := ResolvePrefix("", false); got != "." {
+		t.Errorf("ResolvePrefix() without .specify/ = %s, want .", got)
+	}
+
+	if err := os.Mkdir(".specify", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ResolvePrefix("", false); got != "spec-kit-agents" {
+		t.Errorf("ResolvePrefix() with .specify/ = %s, want spec-kit-agents", got)
+	}
+}
@@ -0,0 +1,194 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// DriftReport describes how installed files differ from the version lock
+type DriftReport struct {
+	Prefix   string   `json:"prefix"`
+	Checked  int      `json:"checked"`
+	Modified []string `json:"modified"`
+	Missing  []string `json:"missing"`
+	Extra    []string `json:"extra"`
+}
+
+// HasDrift returns true if any installed file differs from the version lock
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Modified) > 0 || len(r.Missing) > 0 || len(r.Extra) > 0
+}
+
+// GetSummary returns a one-line summary of the drift
+func (r *DriftReport) GetSummary() string {
+	if err := r.Err(); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("no drift detected (%d files checked)", r.Checked)
+}
+
+// Err returns an error wrapping ErrDriftDetected if there is drift, or nil
+func (r *DriftReport) Err() error {
+	if !r.HasDrift() {
+		return nil
+	}
+	return fmt.Errorf("%w: %d modified, %d missing, %d extra",
+		ErrDriftDetected, len(r.Modified), len(r.Missing), len(r.Extra))
+}
+
+// VerifyInstallationDeep checks the installation structure and then compares
+// every installed file against the hash recorded in the version lock
+func VerifyInstallationDeep(paths *InstallationPaths) (*DriftReport, error) {
+	if !config.PathExists(paths.VersionLock) {
+		return nil, fmt.Errorf("%w at %s", ErrNotInstalled, paths.Prefix)
+	}
+
+	if !config.IsDirectory(paths.SpecifyDir) {
+		return nil, fmt.Errorf(".specify/ directory not found at %s", paths.SpecifyDir)
+	}
+
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		return nil, fmt.Errorf("version lock is invalid: %w", err)
+	}
+
+	return DetectDrift(paths, lock)
+}
+
+// DetectDrift compares the files recorded in the version lock with the files
+// on disk. Files that differ from their recorded hash are reported as
+// modified, recorded files that no longer exist as missing, and files in
+// installer-owned locations that the lock does not know about as extra.
+func DetectDrift(paths *InstallationPaths, lock *models.VersionLock) (*DriftReport, error) {
+	if len(lock.Files) == 0 {
+		return nil, fmt.Errorf("version lock has no file records (reinstall with --force to enable deep verification)")
+	}
+
+	report := &DriftReport{
+		Prefix:   paths.Prefix,
+		Modified: []string{},
+		Missing:  []string{},
+		Extra:    []string{},
+	}
+
+	// Compare recorded files with disk
+	for _, file := range lock.Files {
+		report.Checked++
+
+		if !config.PathExists(file.Path) {
+			report.Missing = append(report.Missing, file.Path)
+			continue
+		}
+
+		hash, _, err := HashFile(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		if hash != file.SHA256 {
+			report.Modified = append(report.Modified, file.Path)
+		}
+	}
+
+	// Look for files in installer-owned locations that are not recorded. Specs
+	// and memory the user adds to .specify/ are not drift.
+	candidates := []string{}
+	for _, dir := range installerSpecifyDirs {
+		files, err := listFilesRecursive(filepath.Join(paths.SpecifyDir, dir))
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, files...)
+	}
+
+	agents, err := findPrefixedFiles(paths.ClaudeAgents, "cat-", ".md")
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, agents...)
+
+	commands, err := findPrefixedFiles(paths.ClaudeCommands, "speckit.", ".md")
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, commands...)
+
+	for _, path := range candidates {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if _, owned := lock.GetFile(absPath); owned || isSidecarOf(lock, absPath) {
+			continue
+		}
+		report.Extra = append(report.Extra, absPath)
+	}
+
+	sort.Strings(report.Modified)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+
+	return report, nil
+}
+
+// isSidecarOf reports whether path is a .new or .orig file that update wrote
+// next to an owned file it could not merge
+func isSidecarOf(lock *models.VersionLock, path string) bool {
+	for _, suffix := range []string{SidecarNewSuffix, SidecarOrigSuffix} {
+		if owner, found := strings.CutSuffix(path, suffix); found {
+			if _, owned := lock.GetFile(owner); owned {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RecordVerification updates the last verified time in the version lock. It
+// holds the operation lock while it does, since saving also writes a lock
+// loaded from an older schema in the current one.
+func RecordVerification(paths *InstallationPaths, logger Logger) error {
+	opLock, err := AcquireOperationLock(paths, "verify", 0, logger)
+	if err != nil {
+		return err
+	}
+	defer opLock.Release()
+
+	lock, err := loadVersionLock(paths, "verify", logger)
+	if err != nil {
+		return err
+	}
+
+	lock.UpdateVerificationTime()
+
+	return version.SaveVersionLock(lock, paths.VersionLock)
+}
+
+// listFilesRecursive lists all regular files below dir
+func listFilesRecursive(dir string) ([]string, error) {
+	files := []string{}
+	if !config.IsDirectory(dir) {
+		return files, nil
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", dir, err)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,113 @@
+package install
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// recordAllFiles records every file of the fake installation in its lock
+func recordAllFiles(t *testing.T, paths *InstallationPaths) *models.VersionLock {
+	t.Helper()
+
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listFilesRecursive(paths.SpecifyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files,
+		filepath.Join(paths.ClaudeAgents, "cat-documentation.md"),
+		filepath.Join(paths.ClaudeCommands, "speckit.specify.md"),
+	)
+
+	for _, path := range files {
+		hash, size, err := HashFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lock.AddFiles([]models.OwnedFile{{Path: path, Size: size, SHA256: hash}})
+	}
+
+	if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+func TestVerifyInstallationDeep_NoDrift(t *testing.T) {
+	paths, _ := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	// User specs and memory, and sidecars left by an update, are not drift
+	writeTestFile(t, filepath.Join(paths.SpecifyDir, "memory", "notes.md"), "mine")
+	writeTestFile(t, filepath.Join(paths.SpecifyDir, "specs", "001-feature", "spec.md"), "mine")
+	writeTestFile(t, filepath.Join(paths.TemplatesDir, "spec.md"+SidecarNewSuffix), "upstream")
+
+	report, err := VerifyInstallationDeep(paths)
+	if err != nil {
+		t.Fatalf("VerifyInstallationDeep() error = %v", err)
+	}
+	if report.HasDrift() {
+		t.Errorf("VerifyInstallationDeep() reported drift: %s", report.GetSummary())
+	}
+	if report.Checked != 4 {
+		t.Errorf("Checked = %d, want 4", report.Checked)
+	}
+}
+
+func TestVerifyInstallationDeep_Drift(t *testing.T) {
+	paths, _ := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	modified := filepath.Join(paths.ClaudeAgents, "cat-documentation.md")
+	if err := os.WriteFile(modified, []byte("hand-edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(paths.SpecifyDir, "templates", "spec.md")
+	if err := os.Remove(missing); err != nil {
+		t.Fatal(err)
+	}
+
+	extra := filepath.Join(paths.SpecifyDir, "templates", "extra.md")
+	if err := os.WriteFile(extra, []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyInstallationDeep(paths)
+	if err != nil {
+		t.Fatalf("VerifyInstallationDeep() error = %v", err)
+	}
+	if !report.HasDrift() {
+		t.Fatal("VerifyInstallationDeep() reported no drift")
+	}
+
+	if len(report.Modified) != 1 || report.Modified[0] != modified {
+		t.Errorf("Modified = %v, want [%s]", report.Modified, modified)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != missing {
+		t.Errorf("Missing = %v, want [%s]", report.Missing, missing)
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != extra {
+		t.Errorf("Extra = %v, want [%s]", report.Extra, extra)
+	}
+	if err := report.Err(); !errors.Is(err, ErrDriftDetected) {
+		t.Errorf("Err() = %v, want ErrDriftDetected", err)
+	}
+}
+
+func TestDetectDrift_NoFileRecords(t *testing.T) {
+	paths, _ := setupFakeInstallation(t)
+
+	if _, err := VerifyInstallationDeep(paths); err == nil {
+		t.Error("VerifyInstallationDeep() expected error for lock without file records")
+	}
+}
@@ -0,0 +1,33 @@
+package install
+
+import "errors"
+
+// Failure classes returned by the install operations. They are wrapped with
+// details, so test for them with errors.Is.
+var (
+	// ErrNotInstalled is returned when there is no installation at the prefix
+	ErrNotInstalled = errors.New("no installation found")
+
+	// ErrAlreadyInstalled is returned by install when the prefix already has
+	// an installation and --force was not given
+	ErrAlreadyInstalled = errors.New("installation already exists")
+
+	// ErrBackupNotFound is returned when there is no backup to restore
+	ErrBackupNotFound = errors.New("backup not found")
+
+	// ErrBackupCorrupt is returned when a backup no longer matches the
+	// metadata recorded when it was made
+	ErrBackupCorrupt = errors.New("backup is corrupt")
+
+	// ErrDriftDetected is returned when installed files differ from the
+	// version lock
+	ErrDriftDetected = errors.New("drift detected")
+
+	// ErrConflicts is returned when an update stops because local
+	// modifications cannot be merged
+	ErrConflicts = errors.New("conflicting local modifications")
+
+	// ErrLocked is matched by LockedError, returned while another operation
+	// holds the installation
+	ErrLocked = errors.New("another operation in progress")
+)
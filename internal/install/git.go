@@ -0,0 +1,105 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+)
+
+// commitPattern matches a full hexadecimal commit hash
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// fetchGitDependency clones or updates a repository in cacheDir and checks out
+// ref (a tag, commit or branch). When the remote cannot be reached, a ref that
+// is already in the cache is used so reinstalls work offline.
+// Returns the resolved commit hash.
+func fetchGitDependency(repository, ref, cacheDir string, logger Logger) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git is required for git sources: %w", err)
+	}
+
+	if config.IsDirectory(filepath.Join(cacheDir, ".git")) {
+		if err := runGit(cacheDir, "remote", "set-url", "origin", repository); err != nil {
+			return "", err
+		}
+
+		// A commit hash never changes, so there is nothing to fetch if it is cached
+		commit, err := resolveGitRef(cacheDir, ref)
+		if err != nil || !commitPattern.MatchString(ref) {
+			logger.Debug("git", "Fetching %s...", repository)
+			if err := runGit(cacheDir, "fetch", "--quiet", "--tags", "--force", "origin"); err != nil {
+				if commit == "" {
+					return "", err
+				}
+				logger.Warn("git", "Could not fetch %s, using cached %s: %v", repository, ref, err)
+			}
+		}
+	} else {
+		logger.Debug("git", "Cloning %s...", repository)
+		if err := config.EnsureDir(filepath.Dir(cacheDir)); err != nil {
+			return "", err
+		}
+		if err := runGit("", "clone", "--quiet", "--no-checkout", "--", repository, cacheDir); err != nil {
+			return "", err
+		}
+	}
+
+	commit, err := resolveGitRef(cacheDir, ref)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debug("git", "Checking out %s (%s)", ref, commit)
+	if err := runGit(cacheDir, "checkout", "--quiet", "--force", "--detach", commit); err != nil {
+		return "", err
+	}
+	if err := runGit(cacheDir, "clean", "--quiet", "-ffdx"); err != nil {
+		return "", err
+	}
+
+	return commit, nil
+}
+
+// resolveGitRef resolves a tag, commit or branch name to a commit hash. Tags
+// and branches are looked up as fetched from origin, so a branch resolves to
+// the remote head rather than a stale local branch in the cache.
+func resolveGitRef(repoDir, ref string) (string, error) {
+	for _, candidate := range []string{"refs/tags/" + ref, "refs/remotes/origin/" + ref, ref} {
+		out, err := gitOutput(repoDir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil {
+			return out, nil
+		}
+	}
+	return "", fmt.Errorf("ref %s not found in repository", ref)
+}
+
+// runGit runs a git command in dir
+func runGit(dir string, args ...string) error {
+	_, err := gitOutput(dir, args...)
+	return err
+}
+
+// gitOutput runs a git command in dir and returns its trimmed standard output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,116 @@
+package install
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// gitCommit commits a file to the repository in dir and returns the commit hash
+func gitCommit(t *testing.T, dir, file, content string) string {
+	t.Helper()
+	writeTestFile(t, filepath.Join(dir, file), content)
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "update " + file},
+	} {
+		if err := runGit(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit, err := gitOutput(dir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+func TestFetchDependency_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	remote := t.TempDir()
+	if err := runGit(remote, "init", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+	first := gitCommit(t, remote, "spec-kit/templates/spec.md", "v1")
+	if err := runGit(remote, "tag", "v0.0.72"); err != nil {
+		t.Fatal(err)
+	}
+	second := gitCommit(t, remote, "spec-kit/templates/spec.md", "v2")
+
+	paths := &InstallationPaths{CacheDir: filepath.Join(t.TempDir(), ".cache")}
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+
+	fetch := func(ref string) *DependencySource {
+		t.Helper()
+		dep := &models.Dependency{
+			Version:     "0.0.72",
+			Source:      "git",
+			InstallPath: ".specify",
+			Repository:  "file://" + filepath.ToSlash(remote),
+			Ref:         ref,
+			Subdir:      "spec-kit",
+		}
+		source, err := FetchDependency("spec-kit", dep, nil, paths, logger)
+		if err != nil {
+			t.Fatalf("FetchDependency(%s) error = %v", ref, err)
+		}
+		return source
+	}
+
+	tests := []struct {
+		ref         string
+		wantCommit  string
+		wantContent string
+	}{
+		{ref: "v0.0.72", wantCommit: first, wantContent: "v1"},
+		{ref: second, wantCommit: second, wantContent: "v2"},
+		{ref: first, wantCommit: first, wantContent: "v1"},
+	}
+	for _, tt := range tests {
+		source := fetch(tt.ref)
+		if source.Commit != tt.wantCommit {
+			t.Errorf("FetchDependency(%s) commit = %s, want %s", tt.ref, source.Commit, tt.wantCommit)
+		}
+		if got := readTestFile(t, filepath.Join(source.Name, "templates", "spec.md")); got != tt.wantContent {
+			t.Errorf("FetchDependency(%s) content = %q, want %q", tt.ref, got, tt.wantContent)
+		}
+	}
+
+	// A branch follows the remote, not the branch the cache was cloned with
+	branch, err := gitOutput(remote, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	third := gitCommit(t, remote, "spec-kit/templates/spec.md", "v3")
+	if source := fetch(branch); source.Commit != third {
+		t.Errorf("FetchDependency(%s) commit = %s, want the remote head %s", branch, source.Commit, third)
+	}
+
+	// The cache allows fetching without the remote
+	if err := os.RemoveAll(remote); err != nil {
+		t.Fatal(err)
+	}
+	if source := fetch("v0.0.72"); source.Commit != first {
+		t.Errorf("offline FetchDependency() commit = %s, want %s", source.Commit, first)
+	}
+}
+
+func TestFetchDependency_Vendored(t *testing.T) {
+	dep := &models.Dependency{Version: "0.0.72", Source: "vendored", InstallPath: ".specify"}
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+
+	source, err := FetchDependency("spec-kit", dep, &Source{FS: os.DirFS(setupFakeSource(t))}, &InstallationPaths{}, logger)
+	if err != nil {
+		t.Fatalf("FetchDependency() error = %v", err)
+	}
+	if source.Name != vendoredSpecKitDir || source.Commit != "" {
+		t.Errorf("FetchDependency() = %+v, want vendored .specify", source)
+	}
+}
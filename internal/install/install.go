@@ -2,35 +2,44 @@ package install
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
 	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
 )
 
 // Options contains installation configuration options
 type Options struct {
-	Prefix  string
-	Global  bool
-	Force   bool
-	Quiet   bool
-	DryRun  bool
+	Prefix        string
+	Global        bool
+	Force         bool
+	Quiet         bool
+	DryRun        bool
+	SkipIntegrity bool          // Install even if the spec-kit source does not match the manifest integrity hash
+	Source        string        // Source tree to install from (empty = auto-detect, see ResolveSource)
+	Wait          time.Duration // How long to wait for another operation on the installation to finish
+
+	source        *Source           // Set by Update, which has already resolved the source tree
+	specKitSource *DependencySource // Set by Update, which fetches and verifies spec-kit before creating a backup
+	locked        bool              // Set by Update, which already holds the operation lock
 }
 
 // InstallationResult contains the results of an installation
 type InstallationResult struct {
-	Success           bool
-	Mode              string
-	Prefix            string
-	TemplatesVersion  string
-	SpecKitVersion    string
-	FilesInstalled    int
-	ClaudeIntegration *ClaudeIntegrationResult
-	Errors            []error
-	Warnings          []string
+	Success           bool                     `json:"success"`
+	Mode              string                   `json:"mode"`
+	Prefix            string                   `json:"prefix"`
+	TemplatesVersion  string                   `json:"templates_version"`
+	SpecKitVersion    string                   `json:"spec_kit_version"`
+	FilesInstalled    int                      `json:"files_installed"`
+	ClaudeIntegration *ClaudeIntegrationResult `json:"claude_integration,omitempty"`
+	Errors            []error                  `json:"-"`
+	Warnings          []string                 `json:"warnings"`
 }
 
 // Run executes the installation process
-func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
+func Run(opts Options, logger Logger) (*InstallationResult, error) {
 	result := &InstallationResult{
 		Errors:   []error{},
 		Warnings: []string{},
@@ -38,19 +47,51 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 
 	logger.Info("installer", "Starting spec-kit lockstep installation...")
 
-	// Step 1: Verify source files
+	// Step 1: Locate and verify source files
 	logger.Debug("installer", "Verifying source files...")
-	if err := VerifySourceFiles(); err != nil {
-		return nil, fmt.Errorf("source verification failed: %w", err)
+	source := opts.source
+	if source == nil {
+		var err error
+		source, err = ResolveSource(opts.Source)
+		if err != nil {
+			return nil, fmt.Errorf("source verification failed: %w", err)
+		}
 	}
 	logger.Success("installer", "Source files verified")
+	logger.Debug("installer", "Installing from %s (%s)", source, source.Origin)
 
 	// Step 2: Detect installation mode
 	logger.Debug("installer", "Detecting installation mode...")
-	mode, err := DetectMode(opts.Prefix)
+	prefix, err := ResolvePrefix(opts.Prefix, opts.Global)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep other installs, updates and uninstalls out until we are done. A
+	// dry run changes nothing, so it neither creates the prefix nor locks it.
+	if !opts.locked && !opts.DryRun {
+		lockPaths, err := GetPaths(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate installation paths: %w", err)
+		}
+		if err := config.EnsureDir(lockPaths.Prefix); err != nil {
+			return nil, fmt.Errorf("failed to create installation directory: %w", err)
+		}
+		opLock, err := AcquireOperationLock(lockPaths, "install", opts.Wait, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer opLock.Release()
+	}
+
+	mode, err := DetectMode(prefix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect installation mode: %w", err)
 	}
+	if opts.Global && !mode.HasLock {
+		mode.Mode = "global"
+		mode.Description = fmt.Sprintf("Global installation (prefix: %s)", mode.Prefix)
+	}
 	result.Mode = mode.Mode
 	result.Prefix = mode.Prefix
 	logger.Info("installer", "Installation mode: %s", mode.Description)
@@ -59,13 +100,16 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 	if mode.HasLock && !opts.Force {
 		logger.Warn("installer", "Existing installation detected")
 		logger.Info("installer", "Use --force to overwrite existing installation")
-		return nil, fmt.Errorf("installation already exists at %s", mode.Prefix)
+		return nil, fmt.Errorf("%w at %s", ErrAlreadyInstalled, mode.Prefix)
 	}
 
-	// Step 4: Validate installation directory
-	logger.Debug("installer", "Validating installation directory...")
-	if err := ValidateInstallationDirectory(mode.Prefix); err != nil {
-		return nil, fmt.Errorf("installation directory validation failed: %w", err)
+	// Step 4: Validate installation directory, creating it if needed; a dry
+	// run leaves that to the real installation
+	if !opts.DryRun {
+		logger.Debug("installer", "Validating installation directory...")
+		if err := ValidateInstallationDirectory(mode.Prefix); err != nil {
+			return nil, fmt.Errorf("installation directory validation failed: %w", err)
+		}
 	}
 
 	// Step 5: Get all installation paths
@@ -76,7 +120,7 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 
 	// Step 6: Load version manifest
 	logger.Debug("installer", "Loading version manifest...")
-	manifest, err := version.LoadManifestFromPath(".specify/version-manifest.json")
+	manifest, err := source.LoadManifest()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load version manifest: %w", err)
 	}
@@ -86,30 +130,71 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 		return nil, fmt.Errorf("failed to get spec-kit version: %w", err)
 	}
 	result.SpecKitVersion = specKitVersion
-	result.TemplatesVersion = "2.0.0" // TODO: Get from git tag or version file
+
+	templatesVersion, err := version.GetTemplatesVersion(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spec-kit-agents version: %w", err)
+	}
+	result.TemplatesVersion = templatesVersion
 
 	logger.Info("installer", "Installing spec-kit-agents v%s with spec-kit v%s",
 		result.TemplatesVersion, result.SpecKitVersion)
 
 	if opts.DryRun {
+		// Fetching clones or extracts into the cache, so only report it
+		dep, err := manifest.GetSpecKitDependency()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("installer", "Would install spec-kit from %s", describeDependencySource(dep))
 		logger.Info("installer", "Dry run mode - no files will be modified")
 		result.Success = true
 		return result, nil
 	}
 
+	// Step 6a: Fetch spec-kit from the source pinned in the manifest and
+	// verify it against the manifest integrity hash
+	specKitSource := opts.specKitSource
+	if specKitSource == nil {
+		specKitSource, err = fetchSpecKit(manifest, source, paths, opts.SkipIntegrity, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Steps 7-10 write to a staging area, which is swapped into place once
+	// complete; until then the previous installation is untouched
+	txn, err := BeginTransaction(paths, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start installation: %w", err)
+	}
+	installed := false
+	defer func() {
+		if !installed {
+			if err := txn.Rollback(); err != nil {
+				logger.Error("installer", "Failed to roll back installation: %v", err)
+			}
+		}
+	}()
+	staged := txn.Paths()
+
 	// Step 7: Copy .specify/ directory (spec-kit files)
 	logger.Info("installer", "Copying spec-kit files to %s...", paths.SpecifyDir)
-	if err := CopySpecKitFiles(".specify", paths.SpecifyDir); err != nil {
+	specKitDir := SourceDir{FS: specKitSource.FS, Name: specKitSourceName}
+	specKitFiles, err := CopySpecKitFiles(specKitDir, staged.SpecifyDir)
+	if err != nil {
 		return nil, fmt.Errorf("failed to copy spec-kit files: %w", err)
 	}
-
-	specKitFileCount, err := CountFilesRecursive(paths.SpecifyDir)
-	if err != nil {
-		logger.Warn("installer", "Failed to count spec-kit files: %v", err)
-	} else {
-		logger.Success("installer", "Copied %d spec-kit files", specKitFileCount)
-		result.FilesInstalled += specKitFileCount
+	if specKitSource.Source != "vendored" {
+		// The installation is described by our manifest, not the fetched one
+		manifestFile, err := CopyOwnedFile(SourceDir{FS: source.FS}, manifestName, staged.VersionManifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy version manifest: %w", err)
+		}
+		specKitFiles = append(specKitFiles, manifestFile)
 	}
+	logger.Success("installer", "Copied %d spec-kit files", len(specKitFiles))
+	result.FilesInstalled += len(specKitFiles)
 
 	// Step 8: Set up .claude/ directory structure
 	logger.Info("installer", "Setting up Claude Code integration...")
@@ -118,7 +203,7 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 	}
 
 	// Step 9: Integrate with Claude Code (copy agents and commands)
-	claudeResult, err := IntegrateWithClaude(paths)
+	claudeResult, err := IntegrateWithClaude(staged, source, specKitDir)
 	if err != nil {
 		return nil, fmt.Errorf("Claude Code integration failed: %w", err)
 	}
@@ -132,10 +217,38 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 		result.SpecKitVersion,
 		paths.Prefix,
 	)
+	if specKitComp, err := versionLock.GetComponent("spec-kit"); err == nil {
+		specKitComp.InstalledFrom = specKitSource.Source
+		specKitComp.Commit = specKitSource.Commit
+		versionLock.SetComponent("spec-kit", *specKitComp)
+	}
+	stampOperation(versionLock, logger)
+	versionLock.AddFiles(specKitFiles)
+	versionLock.AddFiles(claudeResult.Files)
+
+	// Keep pristine copies as the merge base for future updates
+	if err := StorePristine(staged.PristineDir, versionLock.Files); err != nil {
+		logger.Warn("installer", "Failed to store pristine copies: %v", err)
+		result.Warnings = append(result.Warnings, "local modifications cannot be merged on the next update")
+	}
 
-	if err := version.SaveVersionLock(versionLock, paths.VersionLock); err != nil {
+	// The lock records where the files end up, not where they were staged
+	for i := range versionLock.Files {
+		file := &versionLock.Files[i]
+		file.Path = txn.LivePath(file.Path)
+	}
+	for i := range claudeResult.Files {
+		claudeResult.Files[i].Path = txn.LivePath(claudeResult.Files[i].Path)
+	}
+
+	if err := version.SaveVersionLock(versionLock, staged.VersionLock); err != nil {
 		return nil, fmt.Errorf("failed to save version lock: %w", err)
 	}
+
+	// Swap the staged installation into place
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit installation: %w", err)
+	}
 	logger.Success("installer", "Version lock created at %s", paths.VersionLock)
 
 	// Step 11: Verify installation
@@ -145,6 +258,17 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 	}
 	logger.Success("installer", "Installation verified")
 
+	installed = true
+	if err := txn.Finish(); err != nil {
+		logger.Warn("installer", "Failed to clean up after installation: %v", err)
+	}
+
+	// Let uninstalls of other installations know which Claude Code files
+	// this one uses
+	if err := registerInstallation(paths); err != nil {
+		logger.Warn("installer", "Failed to record the installation: %v", err)
+	}
+
 	// Installation complete
 	result.Success = true
 	logger.Success("installer", "Installation complete!")
@@ -164,6 +288,75 @@ func Run(opts Options, logger *config.Logger) (*InstallationResult, error) {
 	return result, nil
 }
 
+// fetchSpecKit makes the spec-kit files available from the source pinned in
+// the manifest and enforces the manifest integrity hash unless skipped.
+// Archives are verified before extraction, other sources after fetching.
+func fetchSpecKit(manifest *models.Manifest, source *Source, paths *InstallationPaths, skipIntegrity bool, logger Logger) (*DependencySource, error) {
+	dep, err := manifest.GetSpecKitDependency()
+	if err != nil {
+		return nil, err
+	}
+
+	switch dep.Source {
+	case "git":
+		logger.Info("installer", "Fetching spec-kit from %s (%s)...", dep.Repository, dep.Ref)
+	case "archive":
+		logger.Info("installer", "Installing spec-kit from %s...", dep.Archive)
+		archive, err := archivePath(dep, source)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifySpecKitSource(dep, skipIntegrity, logger, func() error {
+			return version.VerifyFileIntegrity(archive, dep.Integrity)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	fetched, err := FetchDependency("spec-kit", dep, source, paths, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if fetched.Commit != "" {
+		logger.Success("installer", "Fetched spec-kit at commit %s", fetched.Commit)
+	}
+
+	if dep.Source != "archive" {
+		if err := verifySpecKitSource(dep, skipIntegrity, logger, func() error {
+			return version.VerifyFSIntegrity(fetched.FS, fetched.Name, dep.Integrity)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return fetched, nil
+}
+
+// verifySpecKitSource enforces the manifest integrity hash for the spec-kit
+// source; verify checks the file hash of an archive or the tree hash of the
+// fetched files
+func verifySpecKitSource(dep *models.Dependency, skipIntegrity bool, logger Logger, verify func() error) error {
+	if skipIntegrity {
+		logger.Warn("installer", "Skipping spec-kit integrity verification")
+		return nil
+	}
+
+	logger.Debug("installer", "Verifying spec-kit integrity...")
+	var err error
+	if dep.Integrity == "" {
+		err = fmt.Errorf("manifest does not pin an integrity hash for spec-kit")
+	} else {
+		err = verify()
+	}
+	if err != nil {
+		return fmt.Errorf("spec-kit integrity verification failed: %w (use --skip-integrity to override)", err)
+	}
+	logger.Success("installer", "spec-kit integrity verified")
+
+	return nil
+}
+
 // VerifyInstallation checks that all required files and directories are in place
 func VerifyInstallation(paths *InstallationPaths) error {
 	// Check that .specify/ was copied
@@ -196,6 +389,13 @@ func VerifyInstallation(paths *InstallationPaths) error {
 		return fmt.Errorf("version lock missing spec-kit component: %w", err)
 	}
 
+	// Verify every file recorded in the lock is in place
+	for _, file := range lock.Files {
+		if !config.PathExists(file.Path) {
+			return fmt.Errorf("installed file missing: %s", file.Path)
+		}
+	}
+
 	// Verify Claude Code integration
 	if err := VerifyClaudeIntegration(paths); err != nil {
 		return fmt.Errorf("Claude Code integration verification failed: %w", err)
@@ -204,30 +404,31 @@ func VerifyInstallation(paths *InstallationPaths) error {
 	return nil
 }
 
-// Uninstall removes an installation
-func Uninstall(prefix string, logger *config.Logger) error {
-	logger.Warn("uninstall", "Uninstalling spec-kit-agents from %s", prefix)
-
-	// TODO: Implement uninstallation
-	// - Remove .specify/ directory
-	// - Remove version lock
-	// - Remove .claude/agents/cat-* files
-	// - Remove .claude/commands/speckit.* files
-	// - Add history entry to version lock before removal
-
-	return fmt.Errorf("uninstall not yet implemented")
+// loadVersionLock loads the version lock of the installation at paths,
+// warning when it was damaged and its previous generation was loaded instead
+func loadVersionLock(paths *InstallationPaths, component string, logger Logger) (*models.VersionLock, error) {
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Recovered != nil {
+		logger.Warn(component, "%v", lock.Recovered)
+	}
+	return lock, nil
 }
 
 // Status displays the current installation status
 type InstallationStatus struct {
-	Installed         bool
-	Prefix            string
-	TemplatesVersion  string
-	SpecKitVersion    string
-	InstalledAt       string
-	LastVerified      string
-	InstallationID    string
-	HistoryEntryCount int
+	Installed         bool   `json:"installed"`
+	Prefix            string `json:"prefix"`
+	Global            bool   `json:"global"`
+	TemplatesVersion  string `json:"templates_version,omitempty"`
+	SpecKitVersion    string `json:"spec_kit_version,omitempty"`
+	InstalledAt       string `json:"installed_at,omitempty"`
+	LastVerified      string `json:"last_verified,omitempty"`
+	InstallationID    string `json:"installation_id,omitempty"`
+	HistoryEntryCount int    `json:"history_entry_count"`
+	Warning           string `json:"warning,omitempty"` // Set when the version lock was recovered from its backup
 }
 
 // GetStatus retrieves the current installation status
@@ -242,6 +443,11 @@ func GetStatus(prefix string) (*InstallationStatus, error) {
 		return nil, fmt.Errorf("failed to get installation paths: %w", err)
 	}
 
+	// Check whether this is the global installation
+	if globalDir, err := config.GetDefaultInstallDir(); err == nil {
+		status.Global = paths.Prefix == globalDir
+	}
+
 	// Check if version lock exists
 	if !config.PathExists(paths.VersionLock) {
 		status.Installed = false
@@ -253,6 +459,9 @@ func GetStatus(prefix string) (*InstallationStatus, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load version lock: %w", err)
 	}
+	if lock.Recovered != nil {
+		status.Warning = lock.Recovered.Error()
+	}
 
 	status.Installed = true
 	status.InstallationID = lock.InstallationID
@@ -271,3 +480,11 @@ func GetStatus(prefix string) (*InstallationStatus, error) {
 
 	return status, nil
 }
+
+// stampOperation records the operation ID of logger in the latest history
+// entry of lock, so that the entry can be matched with the install log
+func stampOperation(lock *models.VersionLock, logger Logger) {
+	if len(lock.History) > 0 {
+		lock.History[len(lock.History)-1].OperationID = logger.OperationID()
+	}
+}
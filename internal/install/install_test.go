@@ -0,0 +1,101 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+)
+
+func TestRun_DryRunWritesNothing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+
+	source := writeTestSource(t, "2.0.0", "# Plan\n")
+	result, err := Run(Options{Prefix: prefix, DryRun: true, SkipIntegrity: true, Source: source}, logger)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Success {
+		t.Error("Run() did not succeed")
+	}
+	if config.PathExists(prefix) {
+		t.Errorf("dry run created %s", prefix)
+	}
+
+	// Nor does it lock an existing prefix
+	if err := os.Mkdir(prefix, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(Options{Prefix: prefix, DryRun: true, SkipIntegrity: true, Source: source}, logger); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if entries, err := os.ReadDir(prefix); err != nil || len(entries) != 0 {
+		t.Errorf("dry run wrote %v to %s (%v)", entries, prefix, err)
+	}
+}
+
+func TestRun_DryRunDoesNotFetch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+
+	// Neither source exists, so fetching either would fail
+	for _, dep := range []string{
+		`{"version": "0.0.72", "source": "git", "install_path": ".specify", "repository": "file:///nonexistent/spec-kit", "ref": "v0.0.72"}`,
+		`{"version": "0.0.72", "source": "archive", "install_path": ".specify", "archive": "spec-kit.tar.gz"}`,
+	} {
+		source := writeTestSource(t, "2.0.0", "# Plan\n")
+		writeTestFile(t, filepath.Join(source, ".specify", "version-manifest.json"),
+			`{"version": "1.0", "name": "spec-kit-agents", "templates_version": "2.0.0", "dependencies": {"spec-kit": `+dep+`}}`)
+
+		if _, err := Run(Options{Prefix: prefix, DryRun: true, Source: source}, logger); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if config.PathExists(prefix) {
+			t.Fatalf("dry run created %s", prefix)
+		}
+	}
+}
+
+func TestGetStatus_WarnsOfRecoveredLock(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	// A second save keeps the first lock as the previous generation
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(paths.VersionLock, []byte(`{"version": "1.1", "installation_`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := GetStatus(paths.Prefix)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if !strings.Contains(status.Warning, paths.VersionLock) {
+		t.Errorf("Warning = %q, want it to name %s", status.Warning, paths.VersionLock)
+	}
+
+	// Saving under the operation lock replaces the damaged file
+	if err := RecordVerification(paths, logger); err != nil {
+		t.Fatalf("RecordVerification() error = %v", err)
+	}
+	status, err = GetStatus(paths.Prefix)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Warning != "" {
+		t.Errorf("Warning = %q after the lock was saved, want none", status.Warning)
+	}
+}
@@ -0,0 +1,133 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// installationsFileName lists the installations that share the Claude
+// directory it lives in. It is only changed while the operation lock, which
+// covers the Claude directory, is held.
+const installationsFileName = ".spec-kit-agents-installations.json"
+
+// installationsFile is the content of the installations file
+type installationsFile struct {
+	Prefixes []string `json:"prefixes"`
+}
+
+// installationsPath returns the installations file of the Claude directory
+// the installation at paths uses
+func installationsPath(paths *InstallationPaths) string {
+	return filepath.Join(paths.ClaudeDir, installationsFileName)
+}
+
+// readInstallations returns the installation prefixes recorded in the
+// installations file, or none if it does not exist
+func readInstallations(paths *InstallationPaths) ([]string, error) {
+	data, err := os.ReadFile(installationsPath(paths))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read installations: %w", err)
+	}
+
+	var file installationsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", installationsPath(paths), err)
+	}
+	return file.Prefixes, nil
+}
+
+// writeInstallations replaces the installation prefixes in the installations
+// file, removing it when none are left
+func writeInstallations(paths *InstallationPaths, prefixes []string) error {
+	if len(prefixes) == 0 {
+		if err := os.Remove(installationsPath(paths)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(installationsFile{Prefixes: prefixes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return models.WriteFileAtomic(installationsPath(paths), data, 0644)
+}
+
+// registerInstallation records the installation at paths in the
+// installations file of its Claude directory
+func registerInstallation(paths *InstallationPaths) error {
+	prefixes, err := readInstallations(paths)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(prefixes, paths.Prefix) {
+		return nil
+	}
+	return writeInstallations(paths, append(prefixes, paths.Prefix))
+}
+
+// unregisterInstallation removes the installation at paths from the
+// installations file of its Claude directory
+func unregisterInstallation(paths *InstallationPaths) error {
+	prefixes, err := readInstallations(paths)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(prefixes, paths.Prefix) {
+		return nil
+	}
+	return writeInstallations(paths, slices.DeleteFunc(prefixes, func(prefix string) bool {
+		return prefix == paths.Prefix
+	}))
+}
+
+// sharedClaudeFiles reports which of the Claude Code files owned by the
+// installation at paths another installation sharing the Claude directory
+// owns as well. The other installations are those in the installations file
+// and, since installations made before it existed are not listed, the
+// default installation directory.
+func sharedClaudeFiles(paths *InstallationPaths, files []string, logger Logger) (map[string]string, error) {
+	prefixes, err := readInstallations(paths)
+	if err != nil {
+		return nil, err
+	}
+	if defaultDir, err := config.GetDefaultInstallDir(); err == nil && !slices.Contains(prefixes, defaultDir) {
+		prefixes = append(prefixes, defaultDir)
+	}
+
+	shared := map[string]string{}
+	for _, prefix := range prefixes {
+		if prefix == paths.Prefix {
+			continue
+		}
+		other, err := GetPaths(prefix)
+		if err != nil || !config.PathExists(other.VersionLock) {
+			continue
+		}
+		lock, err := version.LoadVersionLockFromPath(other.VersionLock)
+		if err != nil {
+			logger.Warn("uninstall", "Failed to read the installation at %s: %v", prefix, err)
+			continue
+		}
+		owned, err := FindOwnedClaudeFiles(other, lock)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if slices.Contains(owned, file) {
+				shared[file] = prefix
+			}
+		}
+	}
+
+	return shared, nil
+}
@@ -0,0 +1,275 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/merge"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// ConflictStrategy selects what update does with files that were modified
+// locally and upstream in ways that cannot be merged automatically
+type ConflictStrategy string
+
+const (
+	// ConflictSidecar keeps the local file and writes the new upstream version
+	// next to it as <file>.new and the previously installed version as <file>.orig
+	ConflictSidecar ConflictStrategy = "sidecar"
+	// ConflictMarkers writes the merged file with diff3-style conflict markers
+	ConflictMarkers ConflictStrategy = "markers"
+	// ConflictAbort stops the update before anything is changed
+	ConflictAbort ConflictStrategy = "abort"
+)
+
+// Sidecar file suffixes written by ConflictSidecar
+const (
+	SidecarNewSuffix  = ".new"
+	SidecarOrigSuffix = ".orig"
+)
+
+// ParseConflictStrategy validates a conflict strategy name
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(name); strategy {
+	case ConflictSidecar, ConflictMarkers, ConflictAbort:
+		return strategy, nil
+	case "":
+		return ConflictSidecar, nil
+	default:
+		return "", fmt.Errorf("invalid conflict strategy %q (must be sidecar, markers or abort)", name)
+	}
+}
+
+// LocalChangesResult lists how local modifications were carried across an update
+type LocalChangesResult struct {
+	Preserved []string `json:"preserved"` // Unchanged upstream, local version kept
+	Merged    []string `json:"merged"`    // Merged automatically with the new version
+	Conflicts []string `json:"conflicts"` // Could not be merged, see the conflict strategy
+	Sidecars  []string `json:"sidecars"`  // Written next to conflicting files by ConflictSidecar
+}
+
+// localChange describes how one locally modified file is carried across an update
+type localChange struct {
+	Path    string
+	Outcome string // "preserved", "merged" or "conflict"
+	Base    []byte // Previously installed content, nil if no pristine copy exists
+	Ours    []byte // Local content
+	Theirs  []byte // New upstream content
+	Merged  []byte // Three-way merge result, with conflict markers for conflicts
+}
+
+// localChangesPlan is the set of locally modified files found before an update
+type localChangesPlan struct {
+	Changes []localChange
+}
+
+// Conflicts returns the paths of files that could not be merged automatically
+func (p *localChangesPlan) Conflicts() []string {
+	paths := []string{}
+	for _, change := range p.Changes {
+		if change.Outcome == "conflict" {
+			paths = append(paths, change.Path)
+		}
+	}
+	return paths
+}
+
+// GetConflictReport returns a human-readable list of conflicting files
+func (p *localChangesPlan) GetConflictReport() string {
+	conflicts := p.Conflicts()
+	lines := make([]string, 0, len(conflicts)+1)
+	lines = append(lines, fmt.Sprintf("%d file(s) modified both locally and upstream:", len(conflicts)))
+	for _, path := range conflicts {
+		lines = append(lines, "  "+path)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// planLocalChanges finds installed files that differ from the version lock and
+// works out how each should be carried over to the new version.
+//
+// The merge base is the pristine copy stored when the file was installed, ours
+// is the file on disk and theirs is the file's source in the new version,
+// looked up in the source tree or the new spec-kit tree. Files whose source no
+// longer exists are left to stale file removal.
+func planLocalChanges(paths *InstallationPaths, lock *models.VersionLock, source *Source, specKit *DependencySource) (*localChangesPlan, error) {
+	plan := &localChangesPlan{}
+
+	for _, file := range lock.Files {
+		if file.Source == "" || !config.PathExists(file.Path) {
+			continue
+		}
+
+		hash, _, err := HashFile(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		if hash == file.SHA256 {
+			continue // Not modified locally
+		}
+
+		theirs, err := readUpstreamFile(paths, source, specKit, file.Source)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", file.Source, err)
+		}
+
+		ours, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+
+		change := localChange{
+			Path:   file.Path,
+			Ours:   ours,
+			Theirs: theirs,
+		}
+
+		switch {
+		case bytes.Equal(ours, theirs):
+			continue // Local change matches the new version
+		case hashContent(theirs) == file.SHA256:
+			change.Outcome = "preserved" // Unchanged upstream
+		default:
+			base, err := ReadPristine(paths.PristineDir, file.SHA256)
+			if err != nil {
+				// Without a base the two sets of changes cannot be told apart
+				change.Outcome = "conflict"
+				break
+			}
+
+			merged := merge.ThreeWay(base, ours, theirs, merge.DefaultLabels)
+			change.Base = base
+			change.Merged = merged.Content
+			change.Outcome = "merged"
+			if merged.HasConflicts() {
+				change.Outcome = "conflict"
+			}
+		}
+
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	return plan, nil
+}
+
+// readUpstreamFile reads the new version of a file recorded as a source in the
+// version lock. Names below the spec-kit tree are read from the newly fetched
+// spec-kit files, except for the version manifest, which always comes from the
+// source tree; other names are read from the source tree. Locks written by
+// earlier versions may record absolute sources: those below the installed
+// .specify/ directory are read from the new spec-kit files too, and any
+// others from disk.
+func readUpstreamFile(paths *InstallationPaths, source *Source, specKit *DependencySource, name string) ([]byte, error) {
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(paths.SpecifyDir, filepath.FromSlash(name))
+		if err != nil || !filepath.IsLocal(rel) {
+			return os.ReadFile(name)
+		}
+		name = path.Join(specKitSourceName, filepath.ToSlash(rel))
+	}
+
+	if rel, ok := strings.CutPrefix(name, specKitSourceName+"/"); ok && name != manifestName {
+		return fs.ReadFile(specKit.FS, rel)
+	}
+	return fs.ReadFile(source.FS, name)
+}
+
+// applyLocalChanges writes the planned content over the freshly installed
+// files. Conflicts are resolved according to the strategy.
+func applyLocalChanges(plan *localChangesPlan, strategy ConflictStrategy, logger Logger) (*LocalChangesResult, error) {
+	result := &LocalChangesResult{
+		Preserved: []string{},
+		Merged:    []string{},
+		Conflicts: []string{},
+		Sidecars:  []string{},
+	}
+
+	for _, change := range plan.Changes {
+		switch {
+		case change.Outcome == "preserved":
+			if err := models.WriteFileAtomic(change.Path, change.Ours, 0644); err != nil {
+				return nil, fmt.Errorf("failed to restore local changes to %s: %w", change.Path, err)
+			}
+			result.Preserved = append(result.Preserved, change.Path)
+			logger.Debug("update", "Kept local version of %s", change.Path)
+
+		case change.Outcome == "merged":
+			if err := models.WriteFileAtomic(change.Path, change.Merged, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write merged %s: %w", change.Path, err)
+			}
+			result.Merged = append(result.Merged, change.Path)
+			logger.Debug("update", "Merged local changes into %s", change.Path)
+
+		case strategy == ConflictMarkers && change.Base != nil:
+			if err := models.WriteFileAtomic(change.Path, change.Merged, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write merge conflicts to %s: %w", change.Path, err)
+			}
+			result.Conflicts = append(result.Conflicts, change.Path)
+			logger.Warn("update", "Conflicts in %s, resolve the marked sections", change.Path)
+
+		default:
+			sidecars, err := writeSidecars(change)
+			if err != nil {
+				return nil, err
+			}
+			result.Sidecars = append(result.Sidecars, sidecars...)
+			result.Conflicts = append(result.Conflicts, change.Path)
+			logger.Warn("update", "Conflicts in %s, new version written to %s",
+				change.Path, change.Path+SidecarNewSuffix)
+		}
+	}
+
+	return result, nil
+}
+
+// writeSidecars keeps the local file and writes the new version and, when
+// known, the previously installed version next to it. It returns the
+// sidecars written.
+func writeSidecars(change localChange) ([]string, error) {
+	newPath := change.Path + SidecarNewSuffix
+	if err := models.WriteFileAtomic(newPath, change.Theirs, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", newPath, err)
+	}
+	sidecars := []string{newPath}
+
+	if change.Base != nil {
+		origPath := change.Path + SidecarOrigSuffix
+		if err := models.WriteFileAtomic(origPath, change.Base, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", origPath, err)
+		}
+		sidecars = append(sidecars, origPath)
+	}
+
+	if err := models.WriteFileAtomic(change.Path, change.Ours, 0644); err != nil {
+		return nil, fmt.Errorf("failed to restore local changes to %s: %w", change.Path, err)
+	}
+
+	return sidecars, nil
+}
+
+// recordSidecars adds the sidecars written by applyLocalChanges to the
+// version lock, so that drift checks, status and uninstall know about them
+// and the next update removes them unless they were edited
+func recordSidecars(lock *models.VersionLock, sidecars []string, lockPath string) error {
+	files := make([]models.OwnedFile, 0, len(sidecars))
+	for _, sidecar := range sidecars {
+		hash, size, err := HashFile(sidecar)
+		if err != nil {
+			return err
+		}
+		files = append(files, models.OwnedFile{Path: sidecar, Size: size, SHA256: hash})
+	}
+	lock.AddFiles(files)
+	return version.SaveVersionLock(lock, lockPath)
+}
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLocalChanges(t *testing.T) {
+	base := "# Agent\none\ntwo\nthree\nfour\n"
+
+	tests := []struct {
+		name         string
+		ours         string
+		theirs       string
+		noPristine   bool
+		strategy     ConflictStrategy
+		wantOutcome  string
+		wantContent  string
+		wantSidecars bool
+	}{
+		{
+			name:        "unchanged upstream keeps local version",
+			ours:        "# Agent\none\nlocal\nthree\nfour\n",
+			theirs:      base,
+			wantOutcome: "preserved",
+			wantContent: "# Agent\none\nlocal\nthree\nfour\n",
+		},
+		{
+			name:        "independent changes are merged",
+			ours:        "# Agent\none\nlocal\nthree\nfour\n",
+			theirs:      "# Agent\none\ntwo\nthree\nupstream\n",
+			wantOutcome: "merged",
+			wantContent: "# Agent\none\nlocal\nthree\nupstream\n",
+		},
+		{
+			name:         "conflict writes sidecars",
+			ours:         "# Agent\none\nlocal\nthree\nfour\n",
+			theirs:       "# Agent\none\nupstream\nthree\nfour\n",
+			strategy:     ConflictSidecar,
+			wantOutcome:  "conflict",
+			wantContent:  "# Agent\none\nlocal\nthree\nfour\n",
+			wantSidecars: true,
+		},
+		{
+			name:        "conflict writes markers",
+			ours:        "# Agent\none\nlocal\nthree\nfour\n",
+			theirs:      "# Agent\none\nupstream\nthree\nfour\n",
+			strategy:    ConflictMarkers,
+			wantOutcome: "conflict",
+			wantContent: "# Agent\none\n<<<<<<< local\nlocal\n||||||| base\ntwo\n=======\nupstream\n>>>>>>> upstream\nthree\nfour\n",
+		},
+		{
+			name:         "missing pristine copy is a conflict",
+			ours:         "# Agent\none\nlocal\nthree\nfour\n",
+			theirs:       "# Agent\none\ntwo\nthree\nupstream\n",
+			noPristine:   true,
+			strategy:     ConflictMarkers,
+			wantOutcome:  "conflict",
+			wantContent:  "# Agent\none\nlocal\nthree\nfour\n",
+			wantSidecars: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			paths := &InstallationPaths{PristineDir: filepath.Join(dir, ".pristine")}
+			source := filepath.Join(dir, "source", "cat-agent.md")
+			installed := filepath.Join(dir, "installed", "cat-agent.md")
+
+			// Install the base version and record it
+			writeTestFile(t, installed, base)
+			hash, size, err := HashFile(installed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			owned := models.OwnedFile{Path: installed, Size: size, SHA256: hash, Source: "source/cat-agent.md"}
+			if !tt.noPristine {
+				if err := StorePristine(paths.PristineDir, []models.OwnedFile{owned}); err != nil {
+					t.Fatalf("StorePristine() error = %v", err)
+				}
+			}
+			lock := version.CreateVersionLock("2.0.0", "0.0.72", dir)
+			lock.AddFiles([]models.OwnedFile{owned})
+
+			// Modify locally and publish a new upstream version
+			writeTestFile(t, installed, tt.ours)
+			writeTestFile(t, source, tt.theirs)
+
+			plan, err := planLocalChanges(paths, lock, &Source{Root: dir, FS: os.DirFS(dir)}, nil)
+			if err != nil {
+				t.Fatalf("planLocalChanges() error = %v", err)
+			}
+			if len(plan.Changes) != 1 {
+				t.Fatalf("planLocalChanges() found %d changes, want 1", len(plan.Changes))
+			}
+			if got := plan.Changes[0].Outcome; got != tt.wantOutcome {
+				t.Errorf("planLocalChanges() outcome = %s, want %s", got, tt.wantOutcome)
+			}
+
+			// The update overwrites the file with the new version
+			writeTestFile(t, installed, tt.theirs)
+
+			logger, _ := config.NewLogger(config.FATAL, "", false)
+			if _, err := applyLocalChanges(plan, tt.strategy, logger); err != nil {
+				t.Fatalf("applyLocalChanges() error = %v", err)
+			}
+
+			if got := readTestFile(t, installed); got != tt.wantContent {
+				t.Errorf("installed content =\n%s\nwant\n%s", got, tt.wantContent)
+			}
+
+			if config.PathExists(installed+SidecarNewSuffix) != tt.wantSidecars {
+				t.Errorf("%s exists = %v, want %v", SidecarNewSuffix, !tt.wantSidecars, tt.wantSidecars)
+			}
+			if tt.wantSidecars && readTestFile(t, installed+SidecarNewSuffix) != tt.theirs {
+				t.Errorf("%s does not hold the new version", SidecarNewSuffix)
+			}
+			if wantOrig := tt.wantSidecars && !tt.noPristine; config.PathExists(installed+SidecarOrigSuffix) != wantOrig {
+				t.Errorf("%s exists = %v, want %v", SidecarOrigSuffix, !wantOrig, wantOrig)
+			}
+		})
+	}
+}
+
+func TestPlanLocalChanges_Unmodified(t *testing.T) {
+	paths, _ := setupFakeInstallation(t)
+	lock := recordAllFiles(t, paths)
+
+	plan, err := planLocalChanges(paths, lock, &Source{Root: paths.Prefix, FS: os.DirFS(paths.Prefix)}, nil)
+	if err != nil {
+		t.Fatalf("planLocalChanges() error = %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("planLocalChanges() found %d changes, want 0", len(plan.Changes))
+	}
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	for _, name := range []string{"", "sidecar", "markers", "abort"} {
+		if _, err := ParseConflictStrategy(name); err != nil {
+			t.Errorf("ParseConflictStrategy(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParseConflictStrategy("theirs"); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("ParseConflictStrategy(\"theirs\") error = %v, want invalid strategy", err)
+	}
+}
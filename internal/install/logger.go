@@ -0,0 +1,15 @@
+package install
+
+// Logger is what the install package logs through. config.Logger implements
+// it; to log through another slog handler or *slog.Logger, wrap it with
+// config.NewHandlerLogger, as pkg/install does.
+type Logger interface {
+	Debug(component, format string, args ...any)
+	Info(component, format string, args ...any)
+	Warn(component, format string, args ...any)
+	Error(component, format string, args ...any)
+	Success(component, format string, args ...any)
+
+	// OperationID identifies the running operation in history entries
+	OperationID() string
+}
@@ -0,0 +1,208 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+)
+
+// operationLockName is the lock file taken in the installation prefix and in
+// the Claude directory while an operation modifies them
+const operationLockName = ".spec-kit-agents.lock"
+
+// lockPollInterval is how often a held lock is retried while waiting
+const lockPollInterval = 200 * time.Millisecond
+
+// LockHolder describes the process holding an operation lock
+type LockHolder struct {
+	PID        int       `json:"pid"`
+	Hostname   string    `json:"hostname"`
+	Operation  string    `json:"operation"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// LockedError is returned when another operation holds a lock on the
+// installation or the Claude directory
+type LockedError struct {
+	Path   string
+	Holder *LockHolder // Nil when the lock file could not be read
+}
+
+// Is makes errors.Is(err, ErrLocked) true for a LockedError
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("another operation in progress (lock %s); use --wait to wait for it", e.Path)
+	}
+	return fmt.Sprintf("another operation in progress (pid %d, %s since %s, lock %s); use --wait to wait for it",
+		e.Holder.PID, e.Holder.Operation, e.Holder.AcquiredAt.Local().Format("15:04:05"), e.Path)
+}
+
+// OperationLock is an advisory lock on an installation prefix and the Claude
+// directory, held for the duration of an install, update, rollback or
+// uninstall so that concurrent runs cannot interleave their changes. The
+// lock files are locked with the operating system's file locks, which are
+// released when the holder exits, so a lock is never left held by a process
+// that no longer exists.
+type OperationLock struct {
+	files   []*os.File
+	created []string // Directories created to hold a lock file
+}
+
+// AcquireOperationLock locks the installation prefix and then the Claude
+// directory, creating them if they do not exist yet. If either is held by
+// another process it retries until wait has elapsed, and a wait of zero
+// fails immediately.
+func AcquireOperationLock(paths *InstallationPaths, operation string, wait time.Duration, logger Logger) (*OperationLock, error) {
+	holder := newLockHolder(operation)
+	deadline := time.Now().Add(wait)
+	lock := &OperationLock{}
+
+	for _, dir := range []string{paths.Prefix, paths.ClaudeDir} {
+		if !config.IsDirectory(dir) {
+			if err := config.EnsureDir(dir); err != nil {
+				lock.Release()
+				return nil, fmt.Errorf("failed to create %s for its lock: %w", dir, err)
+			}
+			lock.created = append(lock.created, dir)
+		}
+		file, err := acquireLockFile(filepath.Join(dir, operationLockName), holder, deadline, logger)
+		if err != nil {
+			lock.Release()
+			return nil, err
+		}
+		lock.files = append(lock.files, file)
+	}
+
+	logger.Debug("lock", "Acquired operation lock for %s", operation)
+	return lock, nil
+}
+
+// Release unlocks and removes the lock files, and removes the directories
+// created for them if nothing else was put there. It is safe to call more
+// than once.
+func (l *OperationLock) Release() error {
+	if l == nil {
+		return nil
+	}
+
+	var errs []error
+	for i := len(l.files) - 1; i >= 0; i-- {
+		if err := releaseLockFile(l.files[i]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release lock %s: %w", l.files[i].Name(), err))
+		}
+	}
+	l.files = nil
+
+	for i := len(l.created) - 1; i >= 0; i-- {
+		// Fails, as intended, once the operation has put files there
+		os.Remove(l.created[i])
+	}
+	l.created = nil
+
+	return errors.Join(errs...)
+}
+
+// newLockHolder describes the current process
+func newLockHolder(operation string) *LockHolder {
+	hostname, _ := os.Hostname()
+	return &LockHolder{
+		PID:        os.Getpid(),
+		Hostname:   hostname,
+		Operation:  operation,
+		AcquiredAt: time.Now().UTC(),
+	}
+}
+
+// acquireLockFile opens and locks the lock file, retrying until the deadline
+// while another process holds it, and records the holder in it. A lock file
+// left behind by a process that exited is not locked and is taken over.
+func acquireLockFile(file string, holder *LockHolder, deadline time.Time, logger Logger) (*os.File, error) {
+	data, err := json.MarshalIndent(holder, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	waiting := false
+	for {
+		f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lock %s: %w", file, err)
+		}
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", file, err)
+		}
+
+		if locked {
+			// The previous holder removes the file when it releases the lock,
+			// possibly after we opened it; then we locked a file that is gone
+			if !lockFileCurrent(f, file) {
+				f.Close()
+				continue
+			}
+			if previous := readLockHolder(file); previous != nil {
+				logger.Warn("lock", "Taking over lock left by pid %d (%s)", previous.PID, previous.Operation)
+			}
+			if err := recordLockHolder(f, data); err != nil {
+				releaseLockFile(f)
+				return nil, fmt.Errorf("failed to write lock %s: %w", file, err)
+			}
+			return f, nil
+		}
+		f.Close()
+
+		current := readLockHolder(file)
+		if !time.Now().Before(deadline) {
+			return nil, &LockedError{Path: file, Holder: current}
+		}
+		if !waiting && current != nil {
+			logger.Info("lock", "Waiting for pid %d (%s) to finish...", current.PID, current.Operation)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// lockFileCurrent reports whether the open file is still the one at path
+func lockFileCurrent(f *os.File, path string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(opened, current)
+}
+
+// recordLockHolder replaces the content of a locked lock file
+func recordLockHolder(f *os.File, data []byte) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(data, 0)
+	return err
+}
+
+// readLockHolder returns the holder recorded in a lock file, or nil if it
+// cannot be read, for example while its holder is still writing it
+func readLockHolder(file string) *LockHolder {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	var holder LockHolder
+	if err := json.Unmarshal(data, &holder); err != nil || holder.PID <= 0 {
+		return nil
+	}
+	return &holder
+}
@@ -0,0 +1,29 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package install
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes an exclusive flock on f without blocking, reporting
+// false if another open file holds it
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// releaseLockFile removes the lock file and then unlocks it by closing it, so
+// that a waiter that opened it in the meantime finds it gone and retries
+func releaseLockFile(f *os.File) error {
+	err := os.Remove(f.Name())
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return errors.Join(err, f.Close())
+}
@@ -0,0 +1,23 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package install
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLockFile reports the lock as taken: this platform has no file locks,
+// so operations on an installation are not kept from running concurrently
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// releaseLockFile closes and removes the lock file
+func releaseLockFile(f *os.File) error {
+	err := os.Remove(f.Name())
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return errors.Join(f.Close(), err)
+}
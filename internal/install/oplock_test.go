@@ -0,0 +1,292 @@
+package install
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+)
+
+// writeLockHolder leaves a lock file behind as another process would
+func writeLockHolder(t *testing.T, dir string, holder LockHolder) {
+	t.Helper()
+	data, err := json.Marshal(holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, operationLockName), string(data))
+}
+
+// exitedPID returns the pid of a process that has already exited
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquireOperationLock(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	lock, err := AcquireOperationLock(paths, "install", 0, logger)
+	if err != nil {
+		t.Fatalf("AcquireOperationLock() error = %v", err)
+	}
+
+	_, err = AcquireOperationLock(paths, "update", 0, logger)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second AcquireOperationLock() error = %v, want LockedError", err)
+	}
+	if locked.Holder == nil || locked.Holder.PID != os.Getpid() || locked.Holder.Operation != "install" {
+		t.Errorf("LockedError holder = %+v, want this process running install", locked.Holder)
+	}
+	if !strings.Contains(err.Error(), "another operation in progress (pid ") {
+		t.Errorf("LockedError message = %q", err.Error())
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("second Release() error = %v", err)
+	}
+	for _, dir := range []string{paths.Prefix, paths.ClaudeDir} {
+		if config.PathExists(filepath.Join(dir, operationLockName)) {
+			t.Errorf("lock file left behind in %s", dir)
+		}
+	}
+
+	lock, err = AcquireOperationLock(paths, "update", 0, logger)
+	if err != nil {
+		t.Fatalf("AcquireOperationLock() after release error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireOperationLock_LeftBehind(t *testing.T) {
+	hostname, _ := os.Hostname()
+	paths, logger := setupFakeInstallation(t)
+
+	// A lock file whose holder exited without removing it is not locked
+	writeLockHolder(t, paths.ClaudeDir, LockHolder{PID: exitedPID(t), Hostname: hostname, Operation: "update"})
+
+	lock, err := AcquireOperationLock(paths, "install", 0, logger)
+	if err != nil {
+		t.Fatalf("AcquireOperationLock() error = %v", err)
+	}
+	defer lock.Release()
+
+	holder := readLockHolder(filepath.Join(paths.ClaudeDir, operationLockName))
+	if holder == nil || holder.PID != os.Getpid() || holder.Operation != "install" {
+		t.Errorf("lock holder = %+v, want this process running install", holder)
+	}
+}
+
+func TestAcquireOperationLock_ClaudeDirHeld(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	// The Claude directory is locked second, so failing to lock it must not
+	// leave the prefix locked behind it
+	held, err := acquireLockFile(filepath.Join(paths.ClaudeDir, operationLockName), newLockHolder("update"), time.Now(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseLockFile(held)
+
+	_, err = AcquireOperationLock(paths, "install", 0, logger)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("AcquireOperationLock() error = %v, want LockedError", err)
+	}
+	if config.PathExists(filepath.Join(paths.Prefix, operationLockName)) {
+		t.Error("prefix lock not released after failing to lock the Claude directory")
+	}
+}
+
+func TestAcquireOperationLock_MissingDirectories(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+
+	// A first install into a new prefix and a new ~/.claude
+	paths, err := GetPaths(filepath.Join(t.TempDir(), "spec-kit-agents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := AcquireOperationLock(paths, "install", 0, logger)
+	if err != nil {
+		t.Fatalf("AcquireOperationLock() error = %v", err)
+	}
+	if _, err := AcquireOperationLock(paths, "install", 0, logger); !errors.Is(err, ErrLocked) {
+		t.Errorf("second AcquireOperationLock() error = %v, want ErrLocked", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	for _, dir := range []string{paths.Prefix, paths.ClaudeDir} {
+		if config.PathExists(dir) {
+			t.Errorf("empty directory %s created for the lock was left behind", dir)
+		}
+	}
+}
+
+func TestAcquireOperationLock_Concurrent(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	var inside, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := AcquireOperationLock(paths, "update", 30*time.Second, logger)
+			if err != nil {
+				t.Errorf("AcquireOperationLock() error = %v", err)
+				return
+			}
+			if inside.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			time.Sleep(10 * time.Millisecond)
+			inside.Add(-1)
+			lock.Release()
+		}()
+	}
+	wg.Wait()
+
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("lock was held by more than one caller %d time(s)", n)
+	}
+}
+
+// TestHelperHoldLock holds the operation lock on the prefix in $LOCK_PREFIX
+// for TestAcquireOperationLock_HolderExits until it is killed
+func TestHelperHoldLock(t *testing.T) {
+	prefix := os.Getenv("LOCK_PREFIX")
+	if prefix == "" {
+		t.Skip("helper process")
+	}
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+	if _, err := AcquireOperationLock(paths, "update", 0, logger); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+	time.Sleep(time.Minute)
+}
+
+func TestAcquireOperationLock_HolderExits(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperHoldLock$")
+	cmd.Env = append(os.Environ(), "LOCK_PREFIX="+paths.Prefix)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		t.Fatalf("helper process did not take the lock: %q", line)
+	}
+
+	_, err = AcquireOperationLock(paths, "install", 0, logger)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("AcquireOperationLock() error = %v, want LockedError", err)
+	}
+	if locked.Holder == nil || locked.Holder.PID != cmd.Process.Pid {
+		t.Errorf("LockedError holder = %+v, want pid %d", locked.Holder, cmd.Process.Pid)
+	}
+
+	// The lock is released when its holder dies, without removing the file
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	lock, err := AcquireOperationLock(paths, "install", 5*time.Second, logger)
+	if err != nil {
+		t.Fatalf("AcquireOperationLock() after holder exited error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireOperationLock_Wait(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	lock, err := AcquireOperationLock(paths, "install", 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Times out while the lock is held
+	start := time.Now()
+	_, err = AcquireOperationLock(paths, "update", 300*time.Millisecond, logger)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("AcquireOperationLock() error = %v, want LockedError", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("AcquireOperationLock() gave up after %v, want at least 300ms", elapsed)
+	}
+
+	// Succeeds once the holder finishes
+	time.AfterFunc(300*time.Millisecond, func() { lock.Release() })
+	waited, err := AcquireOperationLock(paths, "update", 10*time.Second, logger)
+	if err != nil {
+		t.Fatalf("AcquireOperationLock() with wait error = %v", err)
+	}
+	waited.Release()
+}
+
+func TestRestoreBackup_KeepsRuntimeFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	lock, err := AcquireOperationLock(paths, "rollback", 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	writeTestFile(t, paths.InstallLog, "log")
+
+	backup, err := CreateBackup(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	extracted := extractTestBackup(t, backup)
+	if config.PathExists(filepath.Join(extracted, operationLockName)) {
+		t.Error("backup contains the operation lock")
+	}
+	if config.PathExists(filepath.Join(extracted, config.InstallLogFile)) {
+		t.Error("backup contains the install log")
+	}
+
+	if err := RestoreBackup(backup, logger); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if !config.PathExists(filepath.Join(paths.Prefix, operationLockName)) {
+		t.Error("RestoreBackup() removed the operation lock")
+	}
+	if !config.PathExists(paths.InstallLog) {
+		t.Error("RestoreBackup() removed the install log")
+	}
+}
@@ -0,0 +1,56 @@
+package install
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+	errorSharingViolation   = syscall.Errno(32)
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// lockRegion is the byte range that is locked. It lies far beyond the holder
+// written to the file, so that others can still read who holds the lock.
+func lockRegion() *syscall.Overlapped {
+	return &syscall.Overlapped{OffsetHigh: 0x7fffffff}
+}
+
+// tryLockFile takes an exclusive lock on f without blocking, reporting false
+// if another open file holds it
+func tryLockFile(f *os.File) (bool, error) {
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately,
+		0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r != 0 {
+		return true, nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return false, nil
+	}
+	return false, err
+}
+
+// releaseLockFile unlocks and closes the lock file and then removes it.
+// Windows cannot remove a file another process has open; a waiter that has
+// it open is about to take the lock, so the file is left to it.
+func releaseLockFile(f *os.File) error {
+	procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if err := f.Close(); err != nil {
+		return err
+	}
+	err := os.Remove(f.Name())
+	if err == nil || os.IsNotExist(err) || errors.Is(err, errorSharingViolation) || errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		return nil
+	}
+	return err
+}
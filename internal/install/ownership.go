@@ -0,0 +1,207 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// FindOwnedClaudeFiles returns the agents and commands in ~/.claude that were
+// installed by spec-kit-agents. The file list recorded in the version lock is
+// authoritative; locks written before file ownership was tracked fall back to
+// the cat- and speckit. name prefixes.
+func FindOwnedClaudeFiles(paths *InstallationPaths, lock *models.VersionLock) ([]string, error) {
+	if len(lock.Files) > 0 {
+		files := []string{}
+		for _, file := range OwnedFilesUnder(lock, paths.ClaudeDir) {
+			if config.PathExists(file.Path) {
+				files = append(files, file.Path)
+			}
+		}
+		return files, nil
+	}
+
+	files := []string{}
+
+	agents, err := findPrefixedFiles(paths.ClaudeAgents, "cat-", ".md")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, agents...)
+
+	commands, err := findPrefixedFiles(paths.ClaudeCommands, "speckit.", ".md")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, commands...)
+
+	return files, nil
+}
+
+// installerSpecifyDirs are the directories below .specify/ that only the
+// installer writes to. Specs and memory the user adds live next to them.
+var installerSpecifyDirs = []string{"templates", "scripts"}
+
+// FindOwnedSpecifyFiles returns the files below .specify/ that were installed
+// by spec-kit-agents. As with FindOwnedClaudeFiles the version lock is
+// authoritative; older locks fall back to the installer's own directories and
+// the version manifest.
+func FindOwnedSpecifyFiles(paths *InstallationPaths, lock *models.VersionLock) ([]string, error) {
+	if len(lock.Files) > 0 {
+		files := []string{}
+		for _, file := range OwnedFilesUnder(lock, paths.SpecifyDir) {
+			if config.PathExists(file.Path) {
+				files = append(files, file.Path)
+			}
+		}
+		return files, nil
+	}
+
+	files := []string{}
+	for _, dir := range installerSpecifyDirs {
+		found, err := listFilesRecursive(filepath.Join(paths.SpecifyDir, dir))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	if config.PathExists(paths.VersionManifest) {
+		files = append(files, paths.VersionManifest)
+	}
+
+	return files, nil
+}
+
+// userSpecifyDirs are the directories below .specify/ whose installed files
+// the user is expected to edit, such as the project constitution in memory/.
+// Uninstall never removes them.
+var userSpecifyDirs = []string{"memory"}
+
+// keptOnUninstall reports which of the owned files uninstall must keep: those
+// below one of the userSpecifyDirs, and those whose content no longer matches
+// the hash recorded in the lock because the user modified them. The reason
+// for keeping each file is logged.
+func keptOnUninstall(paths *InstallationPaths, lock *models.VersionLock, files []string, logger Logger) (map[string]bool, error) {
+	kept := map[string]bool{}
+
+	for _, file := range files {
+		if inUserSpecifyDir(paths, file) {
+			logger.Info("uninstall", "Keeping %s, which is yours to edit", file)
+			kept[file] = true
+		}
+	}
+
+	for _, file := range files {
+		owned, ok := lock.GetFile(file)
+		if kept[file] || !ok {
+			continue
+		}
+		hash, _, err := HashFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if hash != owned.SHA256 {
+			logger.Warn("uninstall", "Keeping modified file: %s", file)
+			kept[file] = true
+		}
+	}
+
+	return kept, nil
+}
+
+// inUserSpecifyDir reports whether file is below one of the userSpecifyDirs
+func inUserSpecifyDir(paths *InstallationPaths, file string) bool {
+	for _, dir := range userSpecifyDirs {
+		rel, err := filepath.Rel(filepath.Join(paths.SpecifyDir, dir), file)
+		if err == nil && filepath.IsLocal(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnedFilesUnder returns the files recorded in the lock that live inside dir
+func OwnedFilesUnder(lock *models.VersionLock, dir string) []models.OwnedFile {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	files := []models.OwnedFile{}
+	for _, file := range lock.Files {
+		rel, err := filepath.Rel(absDir, file.Path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		files = append(files, file)
+	}
+
+	return files
+}
+
+// removeStaleFiles removes files owned by the previous installation that the
+// new installation no longer ships. Files the user has modified since they
+// were installed are kept and reported as warnings.
+func removeStaleFiles(previous, current *models.VersionLock, logger Logger) ([]string, []string) {
+	removed := []string{}
+	warnings := []string{}
+
+	for _, file := range previous.Files {
+		if _, stillOwned := current.GetFile(file.Path); stillOwned {
+			continue
+		}
+		if !config.PathExists(file.Path) {
+			continue
+		}
+
+		hash, _, err := HashFile(file.Path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not check %s: %v", file.Path, err))
+			continue
+		}
+		if hash != file.SHA256 {
+			logger.Warn("update", "Keeping modified file no longer shipped: %s", file.Path)
+			warnings = append(warnings, fmt.Sprintf("kept modified file no longer shipped: %s", file.Path))
+			continue
+		}
+
+		logger.Debug("update", "Removing file no longer shipped: %s", file.Path)
+		if err := os.Remove(file.Path); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not remove %s: %v", file.Path, err))
+			continue
+		}
+		removed = append(removed, file.Path)
+	}
+
+	return removed, warnings
+}
+
+// findPrefixedFiles lists regular files in dir whose names start with prefix
+// and end with suffix (non-recursive)
+func findPrefixedFiles(dir, prefix, suffix string) ([]string, error) {
+	if !config.IsDirectory(dir) {
+		return []string{}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,45 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// The pristine store keeps an unmodified copy of every installed file, named
+// by its SHA-256. Update uses it as the common base when merging local
+// modifications with a new upstream version. Every installation stages a new
+// store holding only the files it installs, so copies of files that are no
+// longer installed are dropped when the staged store replaces the old one.
+
+// StorePristine saves the content of each installed file into the pristine
+// store. Files already stored under the same hash are skipped.
+func StorePristine(pristineDir string, files []models.OwnedFile) error {
+	if err := config.EnsureDir(pristineDir); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		blob := filepath.Join(pristineDir, file.SHA256)
+		if config.PathExists(blob) {
+			continue
+		}
+		if err := CopyFile(file.Path, blob); err != nil {
+			return fmt.Errorf("failed to store pristine copy of %s: %w", file.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadPristine returns the pristine content stored under a hash
+func ReadPristine(pristineDir, hash string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(pristineDir, hash))
+	if err != nil {
+		return nil, fmt.Errorf("pristine copy %s not available: %w", hash, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,203 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which backups of an installation are kept. A zero
+// field does not limit the backups. The newest backup is always kept so that
+// there is something to roll back to.
+type RetentionPolicy struct {
+	KeepLast   int           // Keep at most this many backups
+	KeepWithin time.Duration // Remove backups older than this
+	MaxSize    int64         // Remove the oldest backups until the rest take up at most this many bytes on disk
+}
+
+// DefaultRetentionPolicy is applied after an update unless another policy is
+// given
+var DefaultRetentionPolicy = RetentionPolicy{KeepLast: 5}
+
+// IsZero reports whether the policy keeps every backup
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && p.KeepWithin <= 0 && p.MaxSize <= 0
+}
+
+// Select splits backups into those the policy keeps and those it removes,
+// both newest first. A backup in the store counts the objects that no newer
+// kept backup shares with it, as recorded by pruneBackups; any other backup
+// counts BackupInfo.Size.
+func (p RetentionPolicy) Select(backups []*BackupInfo, now time.Time) (keep, remove []*BackupInfo) {
+	sorted := append([]*BackupInfo{}, backups...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	var total int64
+	kept := map[string]bool{} // Objects of the kept backups
+	for i, backup := range sorted {
+		size := backup.Size
+		if backup.objects != nil {
+			size = 0
+			for hash, objectSize := range backup.objects {
+				if !kept[hash] {
+					size += objectSize
+				}
+			}
+		}
+
+		expired := i > 0 && ((p.KeepLast > 0 && i >= p.KeepLast) ||
+			(p.KeepWithin > 0 && backup.CreatedAt.Before(now.Add(-p.KeepWithin))) ||
+			(p.MaxSize > 0 && total+size > p.MaxSize))
+		if expired {
+			remove = append(remove, backup)
+			continue
+		}
+		keep = append(keep, backup)
+		total += size
+		for hash := range backup.objects {
+			kept[hash] = true
+		}
+	}
+
+	return keep, remove
+}
+
+// PruneOptions contains backup pruning configuration
+type PruneOptions struct {
+	Policy        RetentionPolicy
+	IncludePinned bool          // Also apply the policy to pinned backups, see BackupInfo.Pinned
+	DryRun        bool          // Only report the backups that would be removed
+	Wait          time.Duration // How long to wait for another operation on the installation to finish
+}
+
+// PruneResult contains the results of pruning backups
+type PruneResult struct {
+	DryRun     bool          `json:"dry_run"`
+	Kept       []*BackupInfo `json:"kept"`
+	Removed    []*BackupInfo `json:"removed"`
+	FreedBytes int64         `json:"freed_bytes"` // Disk space freed; objects other backups use are not counted
+}
+
+// PruneBackups removes the backups of an installation that the retention
+// policy does not keep
+func PruneBackups(prefix string, opts PruneOptions, logger Logger) (*PruneResult, error) {
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation paths: %w", err)
+	}
+
+	// Keep a rollback from restoring a backup while it is removed
+	if !opts.DryRun {
+		opLock, err := AcquireOperationLock(paths, "prune", opts.Wait, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer opLock.Release()
+	}
+
+	return pruneBackups(prefix, opts.Policy, opts.IncludePinned, opts.DryRun, logger)
+}
+
+// pruneBackups applies a retention policy. Pinned backups are kept and left
+// out of the policy's limits unless includePinned is set. The caller holds
+// the operation lock unless this is a dry run.
+func pruneBackups(prefix string, policy RetentionPolicy, includePinned, dryRun bool, logger Logger) (*PruneResult, error) {
+	backups, err := ListBackups(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	// Backups in the store share objects, so what they take up on disk
+	// depends on which others are kept. Backup directories without
+	// backup.json do not record their size.
+	for _, backup := range backups {
+		if backup.Storage == BackupStorageStore {
+			objects, err := newBackupStore(backup.OriginalPath).objectSizes(backup.BackupPath)
+			if err != nil {
+				logger.Warn("backup", "Failed to measure backup %s: %v", backup.BackupID, err)
+				continue
+			}
+			backup.objects = objects
+			continue
+		}
+		if backup.Size == 0 {
+			if size, err := GetDirectorySize(backup.BackupPath); err == nil {
+				backup.Size = size
+			}
+		}
+	}
+
+	var candidates, pinned []*BackupInfo
+	for _, backup := range backups {
+		if backup.Pinned() && !includePinned {
+			pinned = append(pinned, backup)
+		} else {
+			candidates = append(candidates, backup)
+		}
+	}
+
+	keep, remove := policy.Select(candidates, time.Now().UTC())
+	keep = append(keep, pinned...)
+	sort.SliceStable(keep, func(i, j int) bool {
+		return keep[i].CreatedAt.After(keep[j].CreatedAt)
+	})
+	result := &PruneResult{
+		DryRun:  dryRun,
+		Kept:    append([]*BackupInfo{}, keep...),
+		Removed: []*BackupInfo{},
+	}
+
+	// Measured before the manifests of the removed backups are gone
+	freed, err := freedSpace(remove, keep)
+	if err != nil {
+		logger.Warn("backup", "Failed to calculate the space freed: %v", err)
+	}
+	result.FreedBytes = freed
+
+	for _, backup := range remove {
+		if dryRun {
+			logger.Info("backup", "Would remove backup %s (%s)", backup.BackupID, FormatSize(backup.Size))
+		} else if err := CleanupBackup(backup, logger); err != nil {
+			logger.Warn("backup", "Failed to remove backup %s: %v", backup.BackupID, err)
+			result.Kept = append(result.Kept, backup)
+			continue
+		}
+		result.Removed = append(result.Removed, backup)
+	}
+
+	if len(result.Removed) > 0 && !dryRun {
+		logger.Info("backup", "Removed %d old backup(s), freeing %s", len(result.Removed), FormatSize(result.FreedBytes))
+	}
+
+	return result, nil
+}
+
+// freedSpace returns the disk space that removing backups frees. Backup
+// directories free their whole size, backups in the store only the objects
+// no kept backup uses.
+func freedSpace(remove, keep []*BackupInfo) (int64, error) {
+	var freed int64
+	var store *backupStore
+	var removeManifests, keepManifests []string
+	for _, backup := range remove {
+		if backup.Storage == BackupStorageStore {
+			store = newBackupStore(backup.OriginalPath)
+			removeManifests = append(removeManifests, backup.BackupPath)
+		} else {
+			freed += backup.Size
+		}
+	}
+	if store == nil {
+		return freed, nil
+	}
+
+	for _, backup := range keep {
+		if backup.Storage == BackupStorageStore {
+			keepManifests = append(keepManifests, backup.BackupPath)
+		}
+	}
+	size, err := store.exclusiveSize(removeManifests, keepManifests)
+	return freed + size, err
+}
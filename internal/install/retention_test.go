@@ -0,0 +1,192 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+)
+
+func TestRetentionPolicy_Select(t *testing.T) {
+	now := time.Date(2025, 10, 22, 12, 0, 0, 0, time.UTC)
+	// Oldest first, 100 bytes each
+	var backups []*BackupInfo
+	for _, age := range []time.Duration{96 * time.Hour, 72 * time.Hour, 48 * time.Hour, 24 * time.Hour, time.Hour} {
+		backups = append(backups, &BackupInfo{
+			BackupID:  "backup-" + now.Add(-age).Format("20060102-150405"),
+			CreatedAt: now.Add(-age),
+			Size:      100,
+		})
+	}
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		wantKeep int
+	}{
+		{"zero keeps all", RetentionPolicy{}, 5},
+		{"keep last", RetentionPolicy{KeepLast: 2}, 2},
+		{"keep within", RetentionPolicy{KeepWithin: 50 * time.Hour}, 3},
+		{"max size", RetentionPolicy{MaxSize: 350}, 3},
+		{"strictest limit wins", RetentionPolicy{KeepLast: 4, KeepWithin: 30 * time.Hour}, 2},
+		{"newest is always kept", RetentionPolicy{KeepWithin: time.Minute, MaxSize: 1}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, remove := tt.policy.Select(backups, now)
+			if len(keep) != tt.wantKeep || len(keep)+len(remove) != len(backups) {
+				t.Fatalf("Select() kept %d, removed %d, want %d kept", len(keep), len(remove), tt.wantKeep)
+			}
+			if keep[0] != backups[len(backups)-1] {
+				t.Errorf("Select() did not keep the newest backup first")
+			}
+			for _, backup := range remove {
+				if backup.CreatedAt.After(keep[len(keep)-1].CreatedAt) {
+					t.Errorf("Select() removed %s before an older backup", backup.BackupID)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	// Backups of different ages, without metadata as made by older releases
+	ids := []string{"backup-20251020-120000", "backup-20251021-120000", "backup-20251022-120000"}
+	for i, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, 24 * time.Hour} {
+		dir := paths.Prefix + "." + ids[i]
+		writeTestFile(t, filepath.Join(dir, "file.txt"), "backup")
+		created := time.Now().Add(-age)
+		if err := os.Chtimes(dir, created, created); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := PruneOptions{Policy: RetentionPolicy{KeepLast: 1}, DryRun: true}
+	result, err := PruneBackups(paths.Prefix, opts, logger)
+	if err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	if len(result.Removed) != 2 || result.FreedBytes != 12 {
+		t.Fatalf("PruneBackups() removed %d (%d bytes), want 2 (12 bytes)", len(result.Removed), result.FreedBytes)
+	}
+	for _, id := range ids {
+		if !config.PathExists(paths.Prefix + "." + id) {
+			t.Errorf("dry run removed %s", id)
+		}
+	}
+
+	opts.DryRun = false
+	if _, err := PruneBackups(paths.Prefix, opts, logger); err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	backups, err := ListBackups(paths.Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].BackupID != ids[2] {
+		t.Errorf("ListBackups() after prune = %v, want only %s", backups, ids[2])
+	}
+}
+
+func TestPruneBackups_MaxSizeCountsStoredObjects(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	// Two backups of a large, compressible installation that differ in one
+	// small file take up little more than one compressed copy
+	writeTestFile(t, filepath.Join(paths.TemplatesDir, "large.md"), strings.Repeat("spec-kit template\n", 4096))
+	if _, err := CreateBackup(paths.Prefix, BackupOptions{Trigger: "update"}, logger); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(paths.TemplatesDir, "spec.md"), "changed")
+	newest, err := CreateBackup(paths.Prefix, BackupOptions{Trigger: "update"}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := listFilesRecursive(filepath.Join(newBackupStore(paths.Prefix).dir, storeObjectsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored int64
+	for _, object := range objects {
+		info, err := os.Stat(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored += info.Size()
+	}
+	if stored >= newest.Size {
+		t.Fatalf("store uses %d bytes, want less than one uncompressed backup (%d)", stored, newest.Size)
+	}
+
+	// Both fit in what the store actually uses
+	opts := PruneOptions{Policy: RetentionPolicy{MaxSize: stored}, DryRun: true}
+	result, err := PruneBackups(paths.Prefix, opts, logger)
+	if err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	if len(result.Removed) != 0 {
+		t.Errorf("PruneBackups() with MaxSize %d removed %d backup(s), want none", stored, len(result.Removed))
+	}
+
+	// Only the newest fits in less
+	opts.Policy.MaxSize = stored - 1
+	if result, err = PruneBackups(paths.Prefix, opts, logger); err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	if len(result.Kept) != 1 || result.Kept[0].BackupID != newest.BackupID {
+		t.Errorf("PruneBackups() with MaxSize %d kept %v, want only %s", stored-1, result.Kept, newest.BackupID)
+	}
+}
+
+func TestPruneBackups_KeepsPinned(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	labeled, err := CreateBackup(paths.Prefix, BackupOptions{Trigger: "update", Label: "known good"}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manual, err := BackupInstallation(paths.Prefix, BackupOptions{}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []*BackupInfo
+	for i := 0; i < 3; i++ {
+		backup, err := CreateBackup(paths.Prefix, BackupOptions{Trigger: "update"}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, backup)
+	}
+
+	// Pinned backups neither count towards the limits nor are removed by them
+	opts := PruneOptions{Policy: RetentionPolicy{KeepLast: 2}, DryRun: true}
+	result, err := PruneBackups(paths.Prefix, opts, logger)
+	if err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].BackupID != updates[0].BackupID {
+		t.Errorf("PruneBackups() removed %v, want only %s", result.Removed, updates[0].BackupID)
+	}
+	if len(result.Kept) != 4 {
+		t.Errorf("PruneBackups() kept %d backups, want 4", len(result.Kept))
+	}
+
+	opts.IncludePinned = true
+	if result, err = PruneBackups(paths.Prefix, opts, logger); err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	removed := map[string]bool{}
+	for _, backup := range result.Removed {
+		removed[backup.BackupID] = true
+	}
+	if len(removed) != 3 || !removed[labeled.BackupID] || !removed[manual.BackupID] {
+		t.Errorf("PruneBackups() with pinned backups removed %v, want the 3 oldest", result.Removed)
+	}
+}
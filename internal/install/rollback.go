@@ -1,7 +1,9 @@
 package install
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
 	"github.com/dkoenawan/claude-agent-templates/internal/version"
@@ -9,21 +11,22 @@ import (
 
 // RollbackOptions contains rollback configuration
 type RollbackOptions struct {
-	BackupID string // Specific backup to restore (empty = latest)
-	Force    bool   // Force rollback even if current install seems OK
+	BackupID string        // Specific backup to restore (empty = latest)
+	Force    bool          // Force rollback even if current install seems OK
+	Wait     time.Duration // How long to wait for another operation on the installation to finish
 }
 
 // RollbackResult contains the results of a rollback operation
 type RollbackResult struct {
-	Success           bool
-	RestoredFromID    string
-	PreviousVersion   string
-	RestoredVersion   string
-	ComponentsRestored int
+	Success            bool   `json:"success"`
+	RestoredFromID     string `json:"restored_from_id"`
+	PreviousVersion    string `json:"previous_version"`
+	RestoredVersion    string `json:"restored_version"`
+	ComponentsRestored int    `json:"components_restored"`
 }
 
 // Rollback restores a previous installation from backup
-func Rollback(prefix string, opts RollbackOptions, logger *config.Logger) (*RollbackResult, error) {
+func Rollback(prefix string, opts RollbackOptions, logger Logger) (*RollbackResult, error) {
 	result := &RollbackResult{}
 
 	logger.Info("rollback", "Starting rollback process...")
@@ -34,9 +37,21 @@ func Rollback(prefix string, opts RollbackOptions, logger *config.Logger) (*Roll
 		return nil, fmt.Errorf("failed to get installation paths: %w", err)
 	}
 
+	// Keep other installs, updates and uninstalls out until we are done
+	opLock, err := AcquireOperationLock(paths, "rollback", opts.Wait, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer opLock.Release()
+
+	// Complete or undo an installation that was interrupted
+	if err := RecoverTransaction(paths, logger); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted installation: %w", err)
+	}
+
 	// Record current version before rollback
 	if config.PathExists(paths.VersionLock) {
-		lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+		lock, err := loadVersionLock(paths, "rollback", logger)
 		if err != nil {
 			logger.Warn("rollback", "Failed to load current version lock: %v", err)
 		} else {
@@ -50,20 +65,9 @@ func Rollback(prefix string, opts RollbackOptions, logger *config.Logger) (*Roll
 	var backup *BackupInfo
 	if opts.BackupID != "" {
 		// Restore specific backup
-		backups, err := ListBackups(prefix)
+		backup, err = FindBackup(prefix, opts.BackupID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list backups: %w", err)
-		}
-
-		for _, b := range backups {
-			if b.BackupID == opts.BackupID {
-				backup = b
-				break
-			}
-		}
-
-		if backup == nil {
-			return nil, fmt.Errorf("backup not found: %s", opts.BackupID)
+			return nil, err
 		}
 	} else {
 		// Restore latest backup
@@ -76,6 +80,20 @@ func Rollback(prefix string, opts RollbackOptions, logger *config.Logger) (*Roll
 	logger.Info("rollback", "Restoring from backup: %s", backup.BackupID)
 	logger.Info("rollback", "Created: %s", backup.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
 
+	// Refuse a backup that was tampered with or partly deleted
+	verification, err := VerifyBackup(backup)
+	switch {
+	case errors.Is(err, ErrNoBackupMetadata):
+		logger.Warn("rollback", "Backup %s has no metadata and cannot be verified", backup.BackupID)
+	case err != nil:
+		return nil, fmt.Errorf("failed to verify backup: %w", err)
+	default:
+		if err := verification.Err(); err != nil {
+			return nil, err
+		}
+		logger.Debug("rollback", "Verified %d file(s) in backup %s", verification.Checked, backup.BackupID)
+	}
+
 	// Restore backup
 	if err := RestoreBackup(backup, logger); err != nil {
 		return nil, fmt.Errorf("failed to restore backup: %w", err)
@@ -95,6 +113,7 @@ func Rollback(prefix string, opts RollbackOptions, logger *config.Logger) (*Roll
 
 			// Update version lock with rollback event
 			lock.AddHistoryEntry("rollback", "all", result.RestoredVersion, "success", nil)
+			stampOperation(lock, logger)
 			if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
 				logger.Warn("rollback", "Failed to update version lock: %v", err)
 			}
@@ -135,7 +154,7 @@ func CanRollback(prefix string) (bool, string, error) {
 }
 
 // AutoRollbackOnError is a helper to automatically rollback on installation failure
-func AutoRollbackOnError(backup *BackupInfo, err error, logger *config.Logger) error {
+func AutoRollbackOnError(backup *BackupInfo, err error, logger Logger) error {
 	if err == nil || backup == nil {
 		return err
 	}
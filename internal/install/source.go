@@ -0,0 +1,171 @@
+package install
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	claudeagenttemplates "github.com/dkoenawan/claude-agent-templates"
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// SourceEnvVar names the environment variable that selects the source tree
+// when --source is not given
+const SourceEnvVar = "SPEC_KIT_AGENTS_SOURCE"
+
+// manifestName is the path of the version manifest within a source tree
+const manifestName = ".specify/version-manifest.json"
+
+// Source is a spec-kit-agents source tree containing agents/ and .specify/:
+// a repository checkout on disk, or the payload embedded in the binary
+type Source struct {
+	Root   string // Absolute path of the source tree (empty for the embedded payload)
+	FS     fs.FS  // Files of the source tree
+	Origin string // How the source was found: "flag", "env", "repository" or "embedded"
+}
+
+// SourceDir is a directory of files to install: a filesystem rooted at the
+// directory, and the name recorded in the version lock as the files' source
+type SourceDir struct {
+	FS   fs.FS
+	Name string // Slash-separated; relative to the source tree, or absolute for files on disk outside it
+}
+
+// DiskDir returns a SourceDir for a directory on disk
+func DiskDir(dir string) SourceDir {
+	return SourceDir{FS: os.DirFS(dir), Name: filepath.ToSlash(dir)}
+}
+
+// String describes where the source tree is, for log messages
+func (s *Source) String() string {
+	if s.Root == "" {
+		return "embedded payload"
+	}
+	return s.Root
+}
+
+// Dir returns a directory of the source tree, such as "agents"
+func (s *Source) Dir(name string) (SourceDir, error) {
+	if info, err := fs.Stat(s.FS, name); err != nil || !info.IsDir() {
+		return SourceDir{}, fmt.Errorf("directory %s not found in %s", name, s)
+	}
+
+	sub, err := fs.Sub(s.FS, name)
+	if err != nil {
+		return SourceDir{}, fmt.Errorf("failed to open %s in %s: %w", name, s, err)
+	}
+	return SourceDir{FS: sub, Name: name}, nil
+}
+
+// LoadManifest loads the version manifest of the source tree
+func (s *Source) LoadManifest() (*models.Manifest, error) {
+	data, err := fs.ReadFile(s.FS, manifestName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest from %s: %w", s, err)
+	}
+
+	manifest, err := models.ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest from %s: %w", s, err)
+	}
+	return manifest, nil
+}
+
+// ResolveSource locates the source tree to install from. In order of
+// precedence it uses the explicit directory (the --source flag), the
+// SPEC_KIT_AGENTS_SOURCE environment variable, the repository containing the
+// current directory, the repository containing the executable, and finally
+// the payload embedded in the binary.
+func ResolveSource(explicit string) (*Source, error) {
+	if explicit != "" {
+		return newSource(explicit, "flag")
+	}
+
+	if env := os.Getenv(SourceEnvVar); env != "" {
+		return newSource(env, "env")
+	}
+
+	candidates := []string{}
+	if root, err := DetectRepositoryRoot(); err == nil {
+		candidates = append(candidates, root)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, cwd)
+	}
+	if exe, err := os.Executable(); err == nil {
+		if exe, err = filepath.EvalSymlinks(exe); err == nil {
+			// e.g. <checkout>/bin/spec-kit-agents
+			candidates = append(candidates, filepath.Dir(exe), filepath.Dir(filepath.Dir(exe)))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if VerifySourceFiles(candidate) == nil {
+			return newSource(candidate, "repository")
+		}
+	}
+
+	if err := verifySourceFS(claudeagenttemplates.Payload); err != nil {
+		return nil, fmt.Errorf("spec-kit-agents source not found: run from a repository checkout, or use --source or %s", SourceEnvVar)
+	}
+
+	return EmbeddedSource(), nil
+}
+
+// EmbeddedSource returns the payload embedded in the binary
+func EmbeddedSource() *Source {
+	return &Source{FS: claudeagenttemplates.Payload, Origin: "embedded"}
+}
+
+// newSource validates a source tree and returns it with an absolute root
+func newSource(dir, origin string) (*Source, error) {
+	root, err := config.ToAbsolutePath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source directory %s: %w", dir, err)
+	}
+
+	if err := VerifySourceFiles(root); err != nil {
+		return nil, fmt.Errorf("invalid source directory %s: %w", root, err)
+	}
+
+	return &Source{Root: root, FS: os.DirFS(root), Origin: origin}, nil
+}
+
+// PayloadInfo describes the payload embedded in the binary
+type PayloadInfo struct {
+	TemplatesVersion string `json:"templates_version"`
+	SpecKitVersion   string `json:"spec_kit_version"`
+	Hash             string `json:"hash"` // Tree hash of the payload, in the manifest integrity format
+}
+
+// GetPayloadInfo reports the versions and tree hash of the embedded payload
+func GetPayloadInfo() (*PayloadInfo, error) {
+	source := EmbeddedSource()
+
+	manifest, err := source.LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &PayloadInfo{}
+	if info.TemplatesVersion, err = version.GetTemplatesVersion(manifest); err != nil {
+		return nil, err
+	}
+	if info.SpecKitVersion, err = version.GetSpecKitVersion(manifest); err != nil {
+		return nil, err
+	}
+	if info.Hash, err = version.ComputeFSTreeHash(source.FS); err != nil {
+		return nil, fmt.Errorf("failed to hash embedded payload: %w", err)
+	}
+
+	return info, nil
+}
+
+// sourceName returns the name recorded in the version lock for a file of dir
+func (d SourceDir) sourceName(name string) string {
+	return path.Join(d.Name, name)
+}
@@ -0,0 +1,144 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+)
+
+// setupFakeSource creates a minimal source tree and returns its root
+func setupFakeSource(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".specify", "version-manifest.json"), "{}")
+	writeTestFile(t, filepath.Join(root, "agents", "core", "cat-agent.md"), "# Agent\n")
+	return root
+}
+
+func TestResolveSource(t *testing.T) {
+	flagRoot := setupFakeSource(t)
+	envRoot := setupFakeSource(t)
+	repoRoot := setupFakeSource(t)
+	if err := os.Mkdir(filepath.Join(repoRoot, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repoRoot, "docs", "guides"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		explicit   string
+		env        string
+		cwd        string
+		wantRoot   string
+		wantOrigin string
+		wantErr    string
+	}{
+		{name: "flag wins over env", explicit: flagRoot, env: envRoot, cwd: repoRoot, wantRoot: flagRoot, wantOrigin: "flag"},
+		{name: "env wins over repository", env: envRoot, cwd: repoRoot, wantRoot: envRoot, wantOrigin: "env"},
+		{name: "repository from subdirectory", cwd: filepath.Join(repoRoot, "docs", "guides"), wantRoot: repoRoot, wantOrigin: "repository"},
+		{name: "invalid flag", explicit: t.TempDir(), cwd: repoRoot, wantErr: "invalid source directory"},
+		{name: "embedded payload outside a checkout", cwd: t.TempDir(), wantOrigin: "embedded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SourceEnvVar, tt.env)
+			t.Chdir(tt.cwd)
+
+			source, err := ResolveSource(tt.explicit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveSource() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveSource() error = %v", err)
+			}
+			if source.Root != tt.wantRoot || source.Origin != tt.wantOrigin {
+				t.Errorf("ResolveSource() = %s (%s), want %s (%s)", source.Root, source.Origin, tt.wantRoot, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestEmbeddedSource(t *testing.T) {
+	source := EmbeddedSource()
+	if err := verifySourceFS(source.FS); err != nil {
+		t.Fatalf("embedded payload is incomplete: %v", err)
+	}
+
+	manifest, err := source.LoadManifest()
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+
+	// The embedded spec-kit files must match the integrity hash they were
+	// built with, or installs from the binary would always be rejected
+	dep, err := manifest.GetSpecKitDependency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.Source == "vendored" {
+		specKit, err := source.Dir(vendoredSpecKitDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := version.VerifyFSIntegrity(specKit.FS, specKit.Name, dep.Integrity); err != nil {
+			t.Errorf("embedded spec-kit: %v", err)
+		}
+	}
+}
+
+func TestCopySpecKitFiles_ReadOnlySource(t *testing.T) {
+	// Embedded files report mode 0444 and no executable bits
+	src := SourceDir{
+		FS: fstest.MapFS{
+			"templates/spec.md":      {Data: []byte("# Spec\n"), Mode: 0444},
+			"scripts/bash/common.sh": {Data: []byte("#!/usr/bin/env bash\n"), Mode: 0444},
+			"scripts/bash/local.sh":  {Data: []byte("#!/usr/bin/env bash\n"), Mode: 0700},
+			"memory/constitution.md": {Data: []byte("# Constitution\n"), Mode: 0644},
+		},
+		Name: ".specify",
+	}
+	dst := filepath.Join(t.TempDir(), ".specify")
+
+	files, err := CopySpecKitFiles(src, dst)
+	if err != nil {
+		t.Fatalf("CopySpecKitFiles() error = %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("CopySpecKitFiles() copied %d files, want 4", len(files))
+	}
+
+	wantModes := map[string]os.FileMode{
+		"templates/spec.md":      0644,
+		"scripts/bash/common.sh": 0755,
+		"scripts/bash/local.sh":  0700,
+		"memory/constitution.md": 0644,
+	}
+	for _, file := range files {
+		rel, err := filepath.Rel(dst, file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		if want := ".specify/" + rel; file.Source != want {
+			t.Errorf("%s source = %q, want %q", rel, file.Source, want)
+		}
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != wantModes[rel] {
+			t.Errorf("%s mode = %v, want %v", rel, got, wantModes[rel])
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// An installation is written in three phases so that an interruption at any
+// point leaves either the previous or the new installation in place:
+//
+//  1. Staging: files are written below <prefix>/.transaction/stage and
+//     <claude dir>/.spec-kit-agents-transaction-<id>/stage, never to their
+//     final location.
+//  2. Commit: the journal lists every rename, then each live path is moved
+//     to the backup area and its staged replacement renamed into place.
+//  3. Finish: the backups and staging areas are removed.
+//
+// A transaction interrupted while staging is discarded on the next run; one
+// interrupted while committing is completed, since everything it needs has
+// already been staged. A failed rollback is resumed.
+
+// Transaction states recorded in the journal
+const (
+	txnStaging     = "staging"
+	txnCommitting  = "committing"
+	txnCommitted   = "committed"
+	txnRollingBack = "rolling-back"
+)
+
+// journalFileName is the name of the journal within the transaction directory
+const journalFileName = "journal.json"
+
+// claudeTransactionPrefix names the staging area in the Claude directory.
+// It lives beside agents/ and commands/ so renames stay on one filesystem.
+const claudeTransactionPrefix = ".spec-kit-agents-transaction-"
+
+// Transaction stages an installation and swaps it into place atomically
+type Transaction struct {
+	journal journal
+	dir     string             // <prefix>/.transaction
+	paths   *InstallationPaths // Live paths
+	staged  *InstallationPaths // Paths below the staging areas
+	logger  Logger
+}
+
+// journal is the on-disk record of a transaction
+type journal struct {
+	ID         string             `json:"id"`
+	State      string             `json:"state"`
+	StartedAt  string             `json:"started_at"`
+	Dirs       []string           `json:"dirs"` // Transaction directories to remove when finished
+	Operations []journalOperation `json:"operations,omitempty"`
+}
+
+// journalOperation replaces one live file or directory with its staged version
+type journalOperation struct {
+	Target  string `json:"target"`  // Live path
+	Staged  string `json:"staged"`  // Staged replacement
+	Backup  string `json:"backup"`  // Where the live path is kept until the transaction finishes
+	Existed bool   `json:"existed"` // Whether the live path existed before the commit
+}
+
+// BeginTransaction recovers any interrupted transaction on the installation
+// and starts a new one with empty staging areas
+func BeginTransaction(paths *InstallationPaths, logger Logger) (*Transaction, error) {
+	if err := RecoverTransaction(paths, logger); err != nil {
+		return nil, err
+	}
+
+	id := time.Now().UTC().Format("20060102-150405.000000000")
+	claudeDir := filepath.Join(paths.ClaudeDir, claudeTransactionPrefix+id)
+
+	txn := &Transaction{
+		journal: journal{
+			ID:        id,
+			State:     txnStaging,
+			StartedAt: time.Now().UTC().Format(time.RFC3339),
+			Dirs:      []string{paths.TransactionDir, claudeDir},
+		},
+		dir:    paths.TransactionDir,
+		paths:  paths,
+		logger: logger,
+	}
+
+	stageDir := filepath.Join(paths.TransactionDir, "stage")
+	claudeStageDir := filepath.Join(claudeDir, "stage")
+
+	staged := *paths
+	staged.SpecifyDir = filepath.Join(stageDir, ".specify")
+	staged.TemplatesDir = filepath.Join(staged.SpecifyDir, "templates")
+	staged.VersionManifest = filepath.Join(staged.SpecifyDir, "version-manifest.json")
+	staged.VersionLock = filepath.Join(stageDir, filepath.Base(paths.VersionLock))
+	staged.PristineDir = filepath.Join(stageDir, filepath.Base(paths.PristineDir))
+	staged.ClaudeAgents = filepath.Join(claudeStageDir, filepath.Base(paths.ClaudeAgents))
+	staged.ClaudeCommands = filepath.Join(claudeStageDir, filepath.Base(paths.ClaudeCommands))
+	txn.staged = &staged
+
+	// The journal must exist before anything is staged, so that recovery
+	// can find and discard a partial staging area
+	if err := config.EnsureDir(paths.TransactionDir); err != nil {
+		return nil, fmt.Errorf("failed to create transaction directory: %w", err)
+	}
+	if err := txn.save(); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []string{staged.SpecifyDir, staged.ClaudeAgents, staged.ClaudeCommands} {
+		if err := config.EnsureDir(dir); err != nil {
+			txn.cleanup()
+			return nil, fmt.Errorf("failed to create staging area: %w", err)
+		}
+	}
+
+	// Files in .specify/ that the installer does not own are carried over
+	if config.IsDirectory(paths.SpecifyDir) {
+		if err := CopyDirectory(paths.SpecifyDir, staged.SpecifyDir); err != nil {
+			txn.cleanup()
+			return nil, fmt.Errorf("failed to stage existing .specify/ directory: %w", err)
+		}
+	}
+
+	// The current version lock is staged too, so that saving the new one
+	// keeps it as the previous generation
+	if config.PathExists(paths.VersionLock) {
+		if err := CopyFile(paths.VersionLock, staged.VersionLock); err != nil {
+			txn.cleanup()
+			return nil, fmt.Errorf("failed to stage existing version lock: %w", err)
+		}
+	}
+
+	logger.Debug("transaction", "Staging installation in %s", stageDir)
+	return txn, nil
+}
+
+// Paths returns the installation paths to write staged files to
+func (t *Transaction) Paths() *InstallationPaths {
+	return t.staged
+}
+
+// LivePath returns the final location of a file written below the staging areas
+func (t *Transaction) LivePath(path string) string {
+	roots := []struct{ staged, live string }{
+		{t.staged.SpecifyDir, t.paths.SpecifyDir},
+		{t.staged.ClaudeAgents, t.paths.ClaudeAgents},
+		{t.staged.ClaudeCommands, t.paths.ClaudeCommands},
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(root.staged, path)
+		if err == nil && filepath.IsLocal(rel) {
+			live, err := filepath.Abs(filepath.Join(root.live, rel))
+			if err != nil {
+				return filepath.Join(root.live, rel)
+			}
+			return live
+		}
+	}
+	return path
+}
+
+// Commit swaps the staged files into place. The journal is written before
+// any live path changes, so an interrupted commit is completed by recovery.
+func (t *Transaction) Commit() error {
+	ops, err := t.plan()
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C is held back for the few renames below, so an interactive
+	// interrupt never leaves a half-committed installation behind. A private
+	// channel keeps the handlers of the program embedding us in place.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	t.journal.Operations = ops
+	t.journal.State = txnCommitting
+	if err := t.save(); err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err := op.apply(); err != nil {
+			return fmt.Errorf("failed to commit %s: %w", op.Target, err)
+		}
+	}
+
+	t.journal.State = txnCommitted
+	if err := t.save(); err != nil {
+		return err
+	}
+
+	t.logger.Debug("transaction", "Committed %d change(s)", len(ops))
+	t.passOnInterrupt(interrupts)
+	return nil
+}
+
+// passOnInterrupt raises again an interrupt that Commit held back, now that
+// the installation is complete, so that the program still stops: the handlers
+// of the program embedding us receive it, or without any the default action
+// exits. The journal is committed, so recovery finishes what is left.
+func (t *Transaction) passOnInterrupt(interrupts chan os.Signal) {
+	select {
+	case sig := <-interrupts:
+		signal.Stop(interrupts)
+		t.logger.Warn("transaction", "Interrupt received while committing; the installation was completed first")
+		process, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			err = process.Signal(sig)
+		}
+		if err != nil {
+			t.logger.Warn("transaction", "Failed to pass on the interrupt: %v", err)
+		}
+	default:
+	}
+}
+
+// Rollback discards the staged files and, if the transaction was committed,
+// restores the previous installation
+func (t *Transaction) Rollback() error {
+	if t.journal.State != txnStaging {
+		t.journal.State = txnRollingBack
+		if err := t.save(); err != nil {
+			return err
+		}
+		for i := len(t.journal.Operations) - 1; i >= 0; i-- {
+			if err := t.journal.Operations[i].undo(); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", t.journal.Operations[i].Target, err)
+			}
+		}
+	}
+
+	return t.cleanup()
+}
+
+// Finish removes the previous installation kept for rollback and the
+// staging areas. The transaction must have been committed.
+func (t *Transaction) Finish() error {
+	if t.journal.State != txnCommitted {
+		return fmt.Errorf("transaction %s is %s, not committed", t.journal.ID, t.journal.State)
+	}
+	return t.cleanup()
+}
+
+// RecoverTransaction completes or undoes a transaction that was interrupted
+// on this installation, and does nothing when there is none
+func RecoverTransaction(paths *InstallationPaths, logger Logger) error {
+	if !config.PathExists(paths.TransactionDir) {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(paths.TransactionDir, journalFileName))
+	if os.IsNotExist(err) {
+		// Interrupted before the journal was written: nothing was staged
+		return os.RemoveAll(paths.TransactionDir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read transaction journal: %w", err)
+	}
+
+	txn := &Transaction{dir: paths.TransactionDir, paths: paths, logger: logger}
+	if err := json.Unmarshal(data, &txn.journal); err != nil {
+		return fmt.Errorf("failed to parse transaction journal %s: %w", filepath.Join(paths.TransactionDir, journalFileName), err)
+	}
+
+	switch txn.journal.State {
+	case txnStaging:
+		logger.Warn("transaction", "Discarding interrupted installation started %s", txn.journal.StartedAt)
+		return txn.cleanup()
+
+	case txnCommitting:
+		logger.Warn("transaction", "Completing interrupted installation started %s", txn.journal.StartedAt)
+		for _, op := range txn.journal.Operations {
+			if err := op.apply(); err != nil {
+				return fmt.Errorf("failed to complete interrupted installation at %s: %w", op.Target, err)
+			}
+		}
+		txn.journal.State = txnCommitted
+		return txn.cleanup()
+
+	case txnCommitted:
+		return txn.cleanup()
+
+	case txnRollingBack:
+		logger.Warn("transaction", "Resuming rollback of installation started %s", txn.journal.StartedAt)
+		return txn.Rollback()
+
+	default:
+		return fmt.Errorf("transaction journal has unknown state %q", txn.journal.State)
+	}
+}
+
+// plan lists the renames that swap the staged files into place: the staged
+// top-level entries of the prefix, and each staged Claude Code file
+func (t *Transaction) plan() ([]journalOperation, error) {
+	ops := []journalOperation{}
+	backupDir := filepath.Join(t.dir, "backup")
+
+	for _, entry := range []struct{ staged, live string }{
+		{t.staged.SpecifyDir, t.paths.SpecifyDir},
+		{t.staged.VersionLock, t.paths.VersionLock},
+		{models.VersionLockBackupPath(t.staged.VersionLock), models.VersionLockBackupPath(t.paths.VersionLock)},
+		{t.staged.PristineDir, t.paths.PristineDir},
+	} {
+		if !config.PathExists(entry.staged) {
+			continue
+		}
+		live, err := filepath.Abs(entry.live)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", entry.live, err)
+		}
+		ops = append(ops, journalOperation{
+			Target:  live,
+			Staged:  entry.staged,
+			Backup:  filepath.Join(backupDir, filepath.Base(live)),
+			Existed: config.PathExists(live),
+		})
+	}
+
+	for _, dir := range []struct{ staged, live string }{
+		{t.staged.ClaudeAgents, t.paths.ClaudeAgents},
+		{t.staged.ClaudeCommands, t.paths.ClaudeCommands},
+	} {
+		entries, err := os.ReadDir(dir.staged)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read staging area: %w", err)
+		}
+		claudeBackupDir := filepath.Join(filepath.Dir(filepath.Dir(dir.staged)), "backup", filepath.Base(dir.staged))
+		for _, entry := range entries {
+			target := filepath.Join(dir.live, entry.Name())
+			ops = append(ops, journalOperation{
+				Target:  target,
+				Staged:  filepath.Join(dir.staged, entry.Name()),
+				Backup:  filepath.Join(claudeBackupDir, entry.Name()),
+				Existed: config.PathExists(target),
+			})
+		}
+	}
+
+	return ops, nil
+}
+
+// apply moves the live path aside and the staged path into place. Each step
+// is skipped when already done, so apply can be repeated after a crash.
+func (op journalOperation) apply() error {
+	if !config.PathExists(op.Staged) {
+		return nil // Already in place
+	}
+
+	if op.Existed && !config.PathExists(op.Backup) {
+		if err := config.EnsureDir(filepath.Dir(op.Backup)); err != nil {
+			return err
+		}
+		if err := os.Rename(op.Target, op.Backup); err != nil {
+			return err
+		}
+	}
+
+	if err := config.EnsureDir(filepath.Dir(op.Target)); err != nil {
+		return err
+	}
+	return os.Rename(op.Staged, op.Target)
+}
+
+// undo reverses apply, restoring the live path from the backup. Like apply
+// it can be repeated after a crash.
+func (op journalOperation) undo() error {
+	if op.Existed {
+		if !config.PathExists(op.Backup) {
+			return nil // Never moved aside, or already restored
+		}
+		if err := os.RemoveAll(op.Target); err != nil {
+			return err
+		}
+		return os.Rename(op.Backup, op.Target)
+	}
+
+	if config.PathExists(op.Staged) {
+		return nil // Never moved into place
+	}
+	return os.RemoveAll(op.Target)
+}
+
+// save writes the journal, replacing the previous version atomically
+func (t *Transaction) save() error {
+	data, err := json.MarshalIndent(t.journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction journal: %w", err)
+	}
+
+	if err := models.WriteFileAtomic(filepath.Join(t.dir, journalFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write transaction journal: %w", err)
+	}
+
+	return nil
+}
+
+// cleanup removes the transaction directories. The journal goes last, so an
+// interrupted cleanup is retried by recovery.
+func (t *Transaction) cleanup() error {
+	for _, dir := range t.journal.Dirs {
+		if dir == t.dir {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+	}
+
+	if err := os.RemoveAll(t.dir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", t.dir, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package install
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+)
+
+// stageNewVersion writes a new version of the fake installation to the
+// staging areas of a transaction
+func stageNewVersion(t *testing.T, txn *Transaction) {
+	t.Helper()
+	staged := txn.Paths()
+	writeTestFile(t, filepath.Join(staged.SpecifyDir, "templates", "spec.md"), "new spec")
+	writeTestFile(t, staged.VersionLock, "new lock")
+	writeTestFile(t, filepath.Join(staged.ClaudeAgents, "cat-documentation.md"), "new agent")
+	writeTestFile(t, filepath.Join(staged.ClaudeAgents, "cat-python.md"), "added agent")
+}
+
+// assertInstalledVersion checks whether the live installation holds the old
+// or the new version, and that no transaction is left behind
+func assertInstalledVersion(t *testing.T, paths *InstallationPaths, wantNew bool) {
+	t.Helper()
+
+	want := map[string]string{
+		filepath.Join(paths.SpecifyDir, "templates", "spec.md"):   "spec",
+		filepath.Join(paths.ClaudeAgents, "cat-documentation.md"): "agent",
+		paths.VersionLock: "lock",
+	}
+	if wantNew {
+		want = map[string]string{
+			filepath.Join(paths.SpecifyDir, "templates", "spec.md"):   "new spec",
+			filepath.Join(paths.ClaudeAgents, "cat-documentation.md"): "new agent",
+			filepath.Join(paths.ClaudeAgents, "cat-python.md"):        "added agent",
+			paths.VersionLock: "new lock",
+		}
+	} else if config.PathExists(filepath.Join(paths.ClaudeAgents, "cat-python.md")) {
+		t.Error("agent added by the transaction was not removed")
+	}
+
+	// Files the installer does not own are never touched
+	want[filepath.Join(paths.SpecifyDir, "version-manifest.json")] = "{}"
+	want[filepath.Join(paths.ClaudeAgents, "my-agent.md")] = "user agent"
+
+	for path, content := range want {
+		if got := readTestFile(t, path); got != content {
+			t.Errorf("%s = %q, want %q", path, got, content)
+		}
+	}
+
+	if config.PathExists(paths.TransactionDir) {
+		t.Error("transaction directory left behind")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(paths.ClaudeDir, claudeTransactionPrefix+"*")); len(matches) > 0 {
+		t.Errorf("Claude staging area left behind: %v", matches)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(t *testing.T, txn *Transaction)
+		wantNew bool
+	}{
+		{
+			name: "commit and finish",
+			run: func(t *testing.T, txn *Transaction) {
+				if err := txn.Commit(); err != nil {
+					t.Fatalf("Commit() error = %v", err)
+				}
+				if err := txn.Finish(); err != nil {
+					t.Fatalf("Finish() error = %v", err)
+				}
+			},
+			wantNew: true,
+		},
+		{
+			name: "rollback before commit",
+			run: func(t *testing.T, txn *Transaction) {
+				if err := txn.Rollback(); err != nil {
+					t.Fatalf("Rollback() error = %v", err)
+				}
+			},
+		},
+		{
+			name: "rollback after commit",
+			run: func(t *testing.T, txn *Transaction) {
+				if err := txn.Commit(); err != nil {
+					t.Fatalf("Commit() error = %v", err)
+				}
+				if err := txn.Rollback(); err != nil {
+					t.Fatalf("Rollback() error = %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, logger := setupFakeInstallation(t)
+			writeTestFile(t, paths.VersionLock, "lock")
+
+			txn, err := BeginTransaction(paths, logger)
+			if err != nil {
+				t.Fatalf("BeginTransaction() error = %v", err)
+			}
+			stageNewVersion(t, txn)
+
+			tt.run(t, txn)
+			assertInstalledVersion(t, paths, tt.wantNew)
+		})
+	}
+}
+
+func TestTransaction_CommitKeepsSignalHandlers(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	writeTestFile(t, paths.VersionLock, "lock")
+
+	// A handler installed by the program using the package
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	txn, err := BeginTransaction(paths, logger)
+	if err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	stageNewVersion(t, txn)
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := txn.Finish(); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot send an interrupt on this platform: %v", err)
+	}
+	select {
+	case <-interrupts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not receive the interrupt after Commit()")
+	}
+}
+
+func TestTransaction_PassesOnInterruptAfterCommit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cannot send an interrupt on this platform")
+	}
+	paths, logger := setupFakeInstallation(t)
+
+	// A handler installed by the program using the package
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	txn, err := BeginTransaction(paths, logger)
+	if err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	defer txn.Rollback()
+
+	// An interrupt held back while committing
+	held := make(chan os.Signal, 1)
+	signal.Notify(held, os.Interrupt)
+	held <- os.Interrupt
+	txn.passOnInterrupt(held)
+
+	select {
+	case <-interrupts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not receive the interrupt held back by Commit()")
+	}
+}
+
+func TestRecoverTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		// interrupt leaves the transaction as a crash would
+		interrupt func(t *testing.T, txn *Transaction)
+		wantNew   bool
+	}{
+		{
+			name:      "interrupted while staging",
+			interrupt: func(t *testing.T, txn *Transaction) {},
+		},
+		{
+			name: "interrupted while committing",
+			interrupt: func(t *testing.T, txn *Transaction) {
+				ops, err := txn.plan()
+				if err != nil {
+					t.Fatal(err)
+				}
+				txn.journal.Operations = ops
+				txn.journal.State = txnCommitting
+				if err := txn.save(); err != nil {
+					t.Fatal(err)
+				}
+				// The first entry is moved aside but its replacement not yet in place
+				if err := os.MkdirAll(filepath.Dir(ops[0].Backup), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(ops[0].Target, ops[0].Backup); err != nil {
+					t.Fatal(err)
+				}
+				if err := ops[1].apply(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantNew: true,
+		},
+		{
+			name: "interrupted after commit",
+			interrupt: func(t *testing.T, txn *Transaction) {
+				if err := txn.Commit(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantNew: true,
+		},
+		{
+			name: "interrupted while rolling back",
+			interrupt: func(t *testing.T, txn *Transaction) {
+				if err := txn.Commit(); err != nil {
+					t.Fatal(err)
+				}
+				txn.journal.State = txnRollingBack
+				if err := txn.save(); err != nil {
+					t.Fatal(err)
+				}
+				last := txn.journal.Operations[len(txn.journal.Operations)-1]
+				if err := last.undo(); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, logger := setupFakeInstallation(t)
+			writeTestFile(t, paths.VersionLock, "lock")
+
+			txn, err := BeginTransaction(paths, logger)
+			if err != nil {
+				t.Fatalf("BeginTransaction() error = %v", err)
+			}
+			stageNewVersion(t, txn)
+			tt.interrupt(t, txn)
+
+			if err := RecoverTransaction(paths, logger); err != nil {
+				t.Fatalf("RecoverTransaction() error = %v", err)
+			}
+			assertInstalledVersion(t, paths, tt.wantNew)
+		})
+	}
+}
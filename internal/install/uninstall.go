@@ -0,0 +1,256 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// UninstallOptions contains uninstall configuration
+type UninstallOptions struct {
+	DryRun bool          // Show what would be removed without removing anything
+	Backup bool          // Keep a backup that can be restored with rollback
+	Wait   time.Duration // How long to wait for another operation on the installation to finish
+}
+
+// UninstallResult contains the results of an uninstall operation
+type UninstallResult struct {
+	Success       bool     `json:"success"`
+	Prefix        string   `json:"prefix"`
+	RemovedPaths  []string `json:"removed_paths"`
+	KeptPaths     []string `json:"kept_paths"` // Owned files kept because the user modified or is expected to edit them
+	BackupCreated bool     `json:"backup_created"`
+	BackupID      string   `json:"backup_id,omitempty"`
+}
+
+// Uninstall removes an installation: the files it put into .specify/ and
+// ~/.claude, its pristine copies and cache, and the version lock. Specs,
+// memory and other files the user added to .specify/ are kept, as are
+// installed files the user has modified, the installed memory/ files and the
+// agents and commands another installation installed as well.
+func Uninstall(prefix string, opts UninstallOptions, logger Logger) (*UninstallResult, error) {
+	result := &UninstallResult{
+		RemovedPaths: []string{},
+		KeptPaths:    []string{},
+	}
+
+	logger.Info("uninstall", "Starting uninstall process...")
+
+	// Get installation paths
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation paths: %w", err)
+	}
+	result.Prefix = paths.Prefix
+
+	// Keep other installs, updates and uninstalls out until we are done
+	opLock, err := AcquireOperationLock(paths, "uninstall", opts.Wait, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer opLock.Release()
+
+	// Complete or undo an installation that was interrupted
+	if err := RecoverTransaction(paths, logger); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted installation: %w", err)
+	}
+
+	// Check if installation exists
+	if !config.PathExists(paths.VersionLock) {
+		return nil, fmt.Errorf("%w at %s", ErrNotInstalled, prefix)
+	}
+
+	lock, err := loadVersionLock(paths, "uninstall", logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load version lock: %w", err)
+	}
+
+	// Collect everything this tool put in place
+	claudeFiles, err := FindOwnedClaudeFiles(paths, lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find installed Claude Code files: %w", err)
+	}
+
+	specifyFiles, err := FindOwnedSpecifyFiles(paths, lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find installed spec-kit files: %w", err)
+	}
+
+	// Keep what the user changed or is expected to edit
+	owned := append(append([]string{}, claudeFiles...), specifyFiles...)
+	kept, err := keptOnUninstall(paths, lock, owned, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check installed files for modifications: %w", err)
+	}
+
+	// Keep the agents and commands another installation installed as well
+	shared, err := sharedClaudeFiles(paths, claudeFiles, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check other installations: %w", err)
+	}
+	for _, file := range claudeFiles {
+		if prefix, ok := shared[file]; ok && !kept[file] {
+			logger.Info("uninstall", "Keeping %s, which the installation at %s also uses", file, prefix)
+			kept[file] = true
+		}
+	}
+
+	targets := []string{}
+	for _, file := range owned {
+		if kept[file] {
+			result.KeptPaths = append(result.KeptPaths, file)
+			continue
+		}
+		targets = append(targets, file)
+	}
+	for _, dir := range []string{paths.PristineDir, paths.CacheDir} {
+		if config.PathExists(dir) {
+			targets = append(targets, dir)
+		}
+	}
+	backupLock := models.VersionLockBackupPath(paths.VersionLock)
+	if config.PathExists(backupLock) {
+		targets = append(targets, backupLock)
+	}
+	targets = append(targets, paths.VersionLock)
+
+	if opts.DryRun {
+		logger.Info("uninstall", "Dry run mode - no files will be removed")
+		for _, target := range targets {
+			logger.Info("uninstall", "  would remove: %s", target)
+		}
+		result.RemovedPaths = targets
+		result.Success = true
+		return result, nil
+	}
+
+	// Record the uninstall in the lock before it is removed, so that a backup
+	// restored with rollback shows how the installation ended
+	templatesVersion := ""
+	if comp, err := lock.GetComponent("spec-kit-agents"); err == nil {
+		templatesVersion = comp.Version
+	}
+	lock.AddHistoryEntry("uninstall", "all", templatesVersion, "success", nil)
+	stampOperation(lock, logger)
+	if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
+		return nil, fmt.Errorf("failed to record uninstall in version lock: %w", err)
+	}
+	if !slices.Contains(targets, backupLock) {
+		// Saving kept the previous generation of the lock
+		targets = append(targets[:len(targets)-1], backupLock, paths.VersionLock)
+	}
+
+	// Create backup if requested
+	if opts.Backup {
+		logger.Info("uninstall", "Creating backup before uninstall...")
+		backup, err := CreateBackup(prefix, BackupOptions{Trigger: "uninstall"}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+		result.BackupCreated = true
+		result.BackupID = backup.BackupID
+	}
+
+	// Remove files
+	for _, target := range targets {
+		logger.Debug("uninstall", "Removing %s", target)
+		if err := os.RemoveAll(target); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+		result.RemovedPaths = append(result.RemovedPaths, target)
+	}
+
+	if err := unregisterInstallation(paths); err != nil {
+		logger.Warn("uninstall", "Failed to remove %s from the installations: %v", paths.Prefix, err)
+	}
+
+	// Remove the directories below .specify/ that are now empty
+	for _, file := range specifyFiles {
+		if err := removeEmptyParents(filepath.Dir(file), paths.SpecifyDir); err != nil {
+			logger.Warn("uninstall", "Failed to remove directory: %v", err)
+		}
+	}
+
+	// Remove the prefix directory itself if it is now empty and is not the
+	// directory we are running from
+	if err := opLock.Release(); err != nil {
+		logger.Warn("uninstall", "%v", err)
+	}
+	if err := removeEmptyPrefix(paths.Prefix); err != nil {
+		logger.Warn("uninstall", "Failed to remove installation directory: %v", err)
+	}
+
+	result.Success = true
+	logger.Success("uninstall", "Removed %d file(s) and directories", len(result.RemovedPaths))
+
+	if result.BackupCreated {
+		logger.Info("uninstall", "To restore: spec-kit-agents rollback --backup-id=%s", result.BackupID)
+	}
+
+	return result, nil
+}
+
+// removeEmptyParents removes dir and its parents up to and including stop
+// while they are empty. Directories outside stop are never removed.
+func removeEmptyParents(dir, stop string) error {
+	stop, err := filepath.Abs(stop)
+	if err != nil {
+		return err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+
+	for {
+		rel, err := filepath.Rel(stop, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+		if err == nil {
+			if err := os.Remove(dir); err != nil {
+				return err
+			}
+		}
+		if rel == "." {
+			return nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// removeEmptyPrefix removes the installation directory if it is empty and is
+// not the current working directory
+func removeEmptyPrefix(prefix string) error {
+	cwd, err := os.Getwd()
+	if err == nil && filepath.Clean(cwd) == filepath.Clean(prefix) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(prefix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(entries) > 0 {
+		return nil
+	}
+
+	return os.Remove(prefix)
+}
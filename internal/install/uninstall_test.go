@@ -0,0 +1,319 @@
+package install
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// setupFakeInstallation creates a minimal installation at prefix with a
+// temporary home directory holding the Claude Code files
+func setupFakeInstallation(t *testing.T) (*InstallationPaths, *config.Logger) {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		t.Fatalf("GetPaths() error = %v", err)
+	}
+
+	files := map[string]string{
+		filepath.Join(paths.SpecifyDir, "version-manifest.json"):  "{}",
+		filepath.Join(paths.SpecifyDir, "templates", "spec.md"):   "spec",
+		filepath.Join(paths.ClaudeAgents, "cat-documentation.md"): "agent",
+		filepath.Join(paths.ClaudeAgents, "my-agent.md"):          "user agent",
+		filepath.Join(paths.ClaudeCommands, "speckit.specify.md"): "command",
+		filepath.Join(paths.ClaudeCommands, "my-command.md"):      "user command",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	lock := version.CreateVersionLock("2.0.0", "0.0.72", paths.Prefix)
+	if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
+		t.Fatalf("failed to save version lock: %v", err)
+	}
+
+	logger, err := config.NewLogger(config.FATAL, "", false)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	return paths, logger
+}
+
+func TestUninstall(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	result, err := Uninstall(paths.Prefix, UninstallOptions{}, logger)
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if !result.Success {
+		t.Fatal("Uninstall() did not succeed")
+	}
+
+	removed := []string{
+		paths.SpecifyDir,
+		paths.VersionLock,
+		filepath.Join(paths.ClaudeAgents, "cat-documentation.md"),
+		filepath.Join(paths.ClaudeCommands, "speckit.specify.md"),
+		paths.Prefix,
+	}
+	for _, path := range removed {
+		if config.PathExists(path) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+
+	kept := []string{
+		filepath.Join(paths.ClaudeAgents, "my-agent.md"),
+		filepath.Join(paths.ClaudeCommands, "my-command.md"),
+	}
+	for _, path := range kept {
+		if !config.PathExists(path) {
+			t.Errorf("expected user file %s to be kept", path)
+		}
+	}
+}
+
+func TestUninstall_KeepsUserSpecifyFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+
+	// Specs and memory the user wrote after installing
+	userFiles := []string{
+		filepath.Join(paths.SpecifyDir, "memory", "notes.md"),
+		filepath.Join(paths.SpecifyDir, "specs", "001-feature", "spec.md"),
+	}
+	for _, path := range userFiles {
+		writeTestFile(t, path, "mine")
+	}
+
+	if _, err := Uninstall(paths.Prefix, UninstallOptions{}, logger); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	for _, path := range userFiles {
+		if got := readTestFile(t, path); got != "mine" {
+			t.Errorf("user file %s = %q, want it kept", path, got)
+		}
+	}
+	for _, path := range []string{paths.TemplatesDir, paths.VersionManifest, paths.VersionLock} {
+		if config.PathExists(path) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+}
+
+func TestUninstall_DryRun(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	result, err := Uninstall(paths.Prefix, UninstallOptions{DryRun: true}, logger)
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if len(result.RemovedPaths) != 5 {
+		t.Errorf("Uninstall() reported %d paths, want 5", len(result.RemovedPaths))
+	}
+
+	for _, path := range result.RemovedPaths {
+		if !config.PathExists(path) {
+			t.Errorf("dry run removed %s", path)
+		}
+	}
+}
+
+func TestUninstall_WithBackup(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	result, err := Uninstall(paths.Prefix, UninstallOptions{Backup: true}, logger)
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if !result.BackupCreated {
+		t.Fatal("Uninstall() did not create a backup")
+	}
+
+	backups, err := ListBackups(paths.Prefix)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].BackupID != result.BackupID {
+		t.Fatalf("ListBackups() = %v, want backup %s", backups, result.BackupID)
+	}
+
+	// The backed-up lock records the uninstall as its final history entry
+	lock, err := version.LoadVersionLockFromPath(filepath.Join(extractTestBackup(t, backups[0]), ".version-lock.json"))
+	if err != nil {
+		t.Fatalf("failed to load backed-up version lock: %v", err)
+	}
+	last := lock.History[len(lock.History)-1]
+	if last.Action != "uninstall" {
+		t.Errorf("last history action = %s, want uninstall", last.Action)
+	}
+	if last.OperationID != logger.OperationID() {
+		t.Errorf("last history operation = %q, want %q", last.OperationID, logger.OperationID())
+	}
+}
+
+func TestUninstall_NotInstalled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+
+	if _, err := Uninstall(t.TempDir(), UninstallOptions{}, logger); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Uninstall() error = %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestUninstall_OwnedFilesOnly(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+
+	// A user-authored agent that happens to use the cat- prefix
+	userAgent := filepath.Join(paths.ClaudeAgents, "cat-my-own.md")
+	if err := os.WriteFile(userAgent, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record ownership of the installed agent only
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownedAgent := filepath.Join(paths.ClaudeAgents, "cat-documentation.md")
+	hash, size, err := HashFile(ownedAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.AddFiles([]models.OwnedFile{{Path: ownedAgent, Size: size, SHA256: hash}})
+	if err := version.SaveVersionLock(lock, paths.VersionLock); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Uninstall(paths.Prefix, UninstallOptions{}, logger); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	if config.PathExists(ownedAgent) {
+		t.Errorf("expected owned agent %s to be removed", ownedAgent)
+	}
+	if !config.PathExists(userAgent) {
+		t.Errorf("expected unowned agent %s to be kept", userAgent)
+	}
+}
+
+func TestUninstall_KeepsModifiedFiles(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	constitution := filepath.Join(paths.SpecifyDir, "memory", "constitution.md")
+	writeTestFile(t, constitution, "# Constitution")
+	recordAllFiles(t, paths)
+
+	// Edited after installing
+	spec := filepath.Join(paths.SpecifyDir, "templates", "spec.md")
+	agent := filepath.Join(paths.ClaudeAgents, "cat-documentation.md")
+	writeTestFile(t, spec, "my spec template")
+	writeTestFile(t, agent, "my agent")
+
+	result, err := Uninstall(paths.Prefix, UninstallOptions{}, logger)
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	for _, path := range []string{spec, agent, constitution} {
+		if !config.PathExists(path) {
+			t.Errorf("expected %s to be kept", path)
+		}
+	}
+	if len(result.KeptPaths) != 3 {
+		t.Errorf("KeptPaths = %v, want the 3 kept files", result.KeptPaths)
+	}
+	for _, path := range []string{paths.VersionManifest, filepath.Join(paths.ClaudeCommands, "speckit.specify.md")} {
+		if config.PathExists(path) {
+			t.Errorf("expected unmodified %s to be removed", path)
+		}
+	}
+}
+
+func TestUninstall_KeepsClaudeFilesOfOtherInstallations(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	recordAllFiles(t, paths)
+	if err := registerInstallation(paths); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second installation, in a project, that installed the same agent
+	other, err := GetPaths(filepath.Join(t.TempDir(), "project"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := filepath.Join(paths.ClaudeAgents, "cat-documentation.md")
+	hash, size, err := HashFile(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(other.Prefix, 0755); err != nil {
+		t.Fatal(err)
+	}
+	lock := version.CreateVersionLock("2.0.0", "0.0.72", other.Prefix)
+	lock.AddFiles([]models.OwnedFile{{Path: agent, Size: size, SHA256: hash}})
+	if err := version.SaveVersionLock(lock, other.VersionLock); err != nil {
+		t.Fatal(err)
+	}
+	if err := registerInstallation(other); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Uninstall(paths.Prefix, UninstallOptions{}, logger)
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+
+	if !config.PathExists(agent) {
+		t.Errorf("Uninstall() removed %s, which the installation at %s uses", agent, other.Prefix)
+	}
+	if len(result.KeptPaths) != 1 || result.KeptPaths[0] != agent {
+		t.Errorf("KeptPaths = %v, want %s", result.KeptPaths, agent)
+	}
+	if command := filepath.Join(paths.ClaudeCommands, "speckit.specify.md"); config.PathExists(command) {
+		t.Errorf("expected %s, which no other installation uses, to be removed", command)
+	}
+
+	prefixes, err := readInstallations(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefixes) != 1 || prefixes[0] != other.Prefix {
+		t.Errorf("installations after uninstall = %v, want only %s", prefixes, other.Prefix)
+	}
+}
+
+func TestUninstall_RemovesConflictSidecars(t *testing.T) {
+	paths, logger := setupFakeInstallation(t)
+	lock := recordAllFiles(t, paths)
+
+	sidecar := filepath.Join(paths.ClaudeCommands, "speckit.specify.md"+SidecarNewSuffix)
+	writeTestFile(t, sidecar, "new command")
+	if err := recordSidecars(lock, []string{sidecar}, paths.VersionLock); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Uninstall(paths.Prefix, UninstallOptions{}, logger); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if config.PathExists(sidecar) {
+		t.Errorf("Uninstall() left %s behind", sidecar)
+	}
+}
@@ -2,32 +2,43 @@ package install
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
 	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
 )
 
 // UpdateOptions contains update configuration
 type UpdateOptions struct {
-	TargetVersion string // Specific version to update to (empty = latest from manifest)
-	Backup        bool   // Create backup before update (default: true)
-	Force         bool   // Force update even if versions match
-	SkipVerify    bool   // Skip version verification
+	TargetVersion string           // Specific version to update to (empty = latest from manifest)
+	Backup        bool             // Create backup before update (default: true)
+	Force         bool             // Force update even if versions match
+	SkipVerify    bool             // Skip version verification
+	SkipIntegrity bool             // Skip spec-kit integrity verification
+	OnConflict    ConflictStrategy // How to handle local changes that cannot be merged (default: sidecar)
+	Source        string           // Source tree to update from (empty = auto-detect, see ResolveSource)
+	Wait          time.Duration    // How long to wait for another operation on the installation to finish
+	Retention     RetentionPolicy  // Applied to the backups after a successful update (zero = keep all)
 }
 
 // UpdateResult contains the results of an update operation
 type UpdateResult struct {
-	Success          bool
-	UpdatedFrom      string
-	UpdatedTo        string
-	BackupCreated    bool
-	BackupID         string
-	ComponentsUpdated int
-	Warnings         []string
+	Success           bool                `json:"success"`
+	UpdatedFrom       string              `json:"updated_from"`
+	UpdatedTo         string              `json:"updated_to"`
+	BackupCreated     bool                `json:"backup_created"`
+	BackupID          string              `json:"backup_id,omitempty"`
+	BackupsRemoved    []string            `json:"backups_removed,omitempty"`
+	ComponentsUpdated int                 `json:"components_updated"`
+	FilesRemoved      []string            `json:"files_removed"`
+	LocalChanges      *LocalChangesResult `json:"local_changes,omitempty"`
+	Warnings          []string            `json:"warnings"`
 }
 
 // Update updates an existing installation to a new version
-func Update(prefix string, opts UpdateOptions, logger *config.Logger) (*UpdateResult, error) {
+func Update(prefix string, opts UpdateOptions, logger Logger) (*UpdateResult, error) {
 	result := &UpdateResult{
 		Warnings: []string{},
 	}
@@ -40,53 +51,58 @@ func Update(prefix string, opts UpdateOptions, logger *config.Logger) (*UpdateRe
 		return nil, fmt.Errorf("failed to get installation paths: %w", err)
 	}
 
+	// Keep other installs, updates and uninstalls out until we are done
+	opLock, err := AcquireOperationLock(paths, "update", opts.Wait, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer opLock.Release()
+
+	// Complete or undo an installation that was interrupted
+	if err := RecoverTransaction(paths, logger); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted installation: %w", err)
+	}
+
 	// Check if installation exists
 	if !config.PathExists(paths.VersionLock) {
-		return nil, fmt.Errorf("no installation found at %s (run 'install' first)", prefix)
+		return nil, fmt.Errorf("%w at %s (run 'install' first)", ErrNotInstalled, prefix)
 	}
 
 	// Load current version lock
-	currentLock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	currentLock, err := loadVersionLock(paths, "update", logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load current version lock: %w", err)
 	}
 
-	// Get current versions
-	currentTemplatesComp, err := currentLock.GetComponent("spec-kit-agents")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current spec-kit-agents version: %w", err)
-	}
-	result.UpdatedFrom = currentTemplatesComp.Version
-
-	currentSpecKitComp, err := currentLock.GetComponent("spec-kit")
+	// Load version manifest from the source tree to get target version
+	source, err := ResolveSource(opts.Source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current spec-kit version: %w", err)
+		return nil, err
 	}
 
-	logger.Info("update", "Current versions:")
-	logger.Info("update", "  spec-kit-agents: v%s", result.UpdatedFrom)
-	logger.Info("update", "  spec-kit: v%s", currentSpecKitComp.Version)
-
-	// Load version manifest to get target version
-	manifest, err := version.LoadManifestFromPath(".specify/version-manifest.json")
+	manifest, err := source.LoadManifest()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load version manifest: %w", err)
 	}
 
-	targetSpecKitVersion, err := version.GetSpecKitVersion(manifest)
+	change, err := compareWithManifest(currentLock, manifest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get target spec-kit version: %w", err)
+		return nil, err
 	}
+	result.UpdatedFrom = change.TemplatesFrom
+	result.UpdatedTo = change.TemplatesTo
+	targetSpecKitVersion := change.SpecKitTo
 
-	targetTemplatesVersion := "2.0.0" // TODO: Get from git tag or version file
-	result.UpdatedTo = targetTemplatesVersion
+	logger.Info("update", "Current versions:")
+	logger.Info("update", "  spec-kit-agents: v%s", change.TemplatesFrom)
+	logger.Info("update", "  spec-kit: v%s", change.SpecKitFrom)
 
 	logger.Info("update", "Target versions:")
-	logger.Info("update", "  spec-kit-agents: v%s", targetTemplatesVersion)
-	logger.Info("update", "  spec-kit: v%s", targetSpecKitVersion)
+	logger.Info("update", "  spec-kit-agents: v%s", change.TemplatesTo)
+	logger.Info("update", "  spec-kit: v%s", change.SpecKitTo)
 
 	// Check if update is needed
-	if result.UpdatedFrom == result.UpdatedTo && currentSpecKitComp.Version == targetSpecKitVersion {
+	if change.IsCurrent() {
 		if !opts.Force {
 			logger.Info("update", "Already at target version, no update needed")
 			logger.Info("update", "Use --force to reinstall anyway")
@@ -95,6 +111,12 @@ func Update(prefix string, opts UpdateOptions, logger *config.Logger) (*UpdateRe
 		}
 		logger.Warn("update", "Forcing update even though versions match")
 		result.Warnings = append(result.Warnings, "forced update with matching versions")
+	} else if change.IsDowngrade() {
+		if !opts.Force {
+			return nil, fmt.Errorf("installed version is newer than the manifest (%s), use --force to downgrade", change)
+		}
+		logger.Warn("update", "Forcing downgrade: %s", change)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("forced downgrade: %s", change))
 	}
 
 	// Check version compatibility
@@ -117,40 +139,54 @@ func Update(prefix string, opts UpdateOptions, logger *config.Logger) (*UpdateRe
 		}
 
 		if !compatResult.IsCompatible() {
-			return nil, fmt.Errorf("target version incompatible: %s", compatResult.GetIssuesText())
+			return nil, fmt.Errorf("target %w: %s", version.ErrIncompatible, compatResult.GetIssuesText())
 		}
 
 		logger.Success("update", "Version compatibility verified")
 	}
 
+	// Fetch and verify the new spec-kit source before touching the installation
+	specKitSource, err := fetchSpecKit(manifest, source, paths, opts.SkipIntegrity, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find local modifications that must survive the update
+	logger.Debug("update", "Checking for local modifications...")
+	localChanges, err := planLocalChanges(paths, currentLock, source, specKitSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for local modifications: %w", err)
+	}
+	if conflicts := localChanges.Conflicts(); len(conflicts) > 0 {
+		logger.Warn("update", "%s", localChanges.GetConflictReport())
+		if opts.OnConflict == ConflictAbort {
+			return nil, fmt.Errorf("update aborted: %d file(s) have %w", len(conflicts), ErrConflicts)
+		}
+	}
+
 	// Create backup if requested
 	var backup *BackupInfo
 	if opts.Backup {
 		logger.Info("update", "Creating backup before update...")
-		backup, err = CreateBackup(prefix, logger)
+		backup, err = CreateBackup(prefix, BackupOptions{Trigger: "update"}, logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create backup: %w", err)
 		}
 		result.BackupCreated = true
 		result.BackupID = backup.BackupID
-
-		// Ensure backup is cleaned up on successful update
-		defer func() {
-			if result.Success && backup != nil {
-				// Keep backup for safety, but could optionally clean up
-				logger.Info("update", "Backup preserved at: %s", backup.BackupPath)
-			}
-		}()
 	}
 
 	// Perform update installation
 	logger.Info("update", "Updating installation...")
 
 	installOpts := Options{
-		Prefix: prefix,
-		Force:  true, // Always force for update
-		Quiet:  false,
-		DryRun: false,
+		Prefix:        prefix,
+		Force:         true, // Always force for update
+		Quiet:         false,
+		DryRun:        false,
+		source:        source,
+		specKitSource: specKitSource, // Fetched and verified above
+		locked:        true,
 	}
 
 	installResult, err := Run(installOpts, logger)
@@ -169,16 +205,60 @@ func Update(prefix string, opts UpdateOptions, logger *config.Logger) (*UpdateRe
 		return nil, fmt.Errorf("update did not complete successfully")
 	}
 
+	// Carry local modifications over to the new version
+	if len(localChanges.Changes) > 0 {
+		logger.Info("update", "Restoring local modifications...")
+		changesResult, err := applyLocalChanges(localChanges, opts.OnConflict, logger)
+		if err != nil {
+			if backup != nil {
+				return nil, AutoRollbackOnError(backup, err, logger)
+			}
+			return nil, fmt.Errorf("update failed: %w", err)
+		}
+		result.LocalChanges = changesResult
+		if len(changesResult.Conflicts) > 0 {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("%d file(s) need manual conflict resolution", len(changesResult.Conflicts)))
+		}
+	}
+
 	// Update version lock with upgrade history
 	updatedLock, err := version.LoadVersionLockFromPath(paths.VersionLock)
 	if err != nil {
 		logger.Warn("update", "Failed to load updated version lock: %v", err)
 	} else {
 		result.ComponentsUpdated = len(updatedLock.Components)
+
+		if result.LocalChanges != nil && len(result.LocalChanges.Sidecars) > 0 {
+			if err := recordSidecars(updatedLock, result.LocalChanges.Sidecars, paths.VersionLock); err != nil {
+				logger.Warn("update", "Failed to record conflict sidecars in the version lock: %v", err)
+			}
+		}
+
+		// Remove files the previous version installed but the new one no longer ships
+		removed, warnings := removeStaleFiles(currentLock, updatedLock, logger)
+		result.FilesRemoved = removed
+		result.Warnings = append(result.Warnings, warnings...)
+		if len(removed) > 0 {
+			logger.Info("update", "Removed %d file(s) no longer shipped", len(removed))
+		}
 	}
 
 	result.Success = true
 	logger.Success("update", "Update completed successfully")
+
+	// Drop the backups the retention policy no longer keeps
+	if !opts.Retention.IsZero() {
+		pruned, err := pruneBackups(prefix, opts.Retention, false, false, logger)
+		if err != nil {
+			logger.Warn("update", "Failed to prune old backups: %v", err)
+		} else {
+			for _, removed := range pruned.Removed {
+				result.BackupsRemoved = append(result.BackupsRemoved, removed.BackupID)
+			}
+		}
+	}
+
 	logger.Info("update", "Updated from v%s to v%s", result.UpdatedFrom, result.UpdatedTo)
 
 	if result.BackupCreated {
@@ -189,8 +269,9 @@ func Update(prefix string, opts UpdateOptions, logger *config.Logger) (*UpdateRe
 	return result, nil
 }
 
-// CheckForUpdates checks if updates are available
-func CheckForUpdates(prefix string, logger *config.Logger) (bool, string, error) {
+// CheckForUpdates checks if the source tree offers newer versions than the
+// installation at prefix. sourceDir selects the source tree as for ResolveSource.
+func CheckForUpdates(prefix, sourceDir string, logger Logger) (bool, string, error) {
 	// Get installation paths
 	paths, err := GetPaths(prefix)
 	if err != nil {
@@ -203,41 +284,107 @@ func CheckForUpdates(prefix string, logger *config.Logger) (bool, string, error)
 	}
 
 	// Load current version lock
-	currentLock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	currentLock, err := loadVersionLock(paths, "update", logger)
 	if err != nil {
 		return false, "", fmt.Errorf("failed to load current version lock: %w", err)
 	}
 
-	// Get current spec-kit version
-	currentSpecKitComp, err := currentLock.GetComponent("spec-kit")
+	// Load version manifest from the source tree to get target version
+	source, err := ResolveSource(sourceDir)
 	if err != nil {
-		return false, "", fmt.Errorf("failed to get current spec-kit version: %w", err)
+		return false, "", err
 	}
 
-	// Load version manifest to get target version
-	manifest, err := version.LoadManifestFromPath(".specify/version-manifest.json")
+	manifest, err := source.LoadManifest()
 	if err != nil {
 		return false, "", fmt.Errorf("failed to load version manifest: %w", err)
 	}
 
-	targetSpecKitVersion, err := version.GetSpecKitVersion(manifest)
+	change, err := compareWithManifest(currentLock, manifest)
+	if err != nil {
+		return false, "", err
+	}
+
+	switch {
+	case change.IsCurrent():
+		return false, fmt.Sprintf("already at latest version (spec-kit-agents v%s, spec-kit v%s)", change.TemplatesTo, change.SpecKitTo), nil
+	case change.IsDowngrade():
+		return false, fmt.Sprintf("installed version is newer than manifest: %s", change), nil
+	default:
+		return true, fmt.Sprintf("update available: %s", change), nil
+	}
+}
+
+// versionChange describes the difference between the installed versions and
+// the versions pinned in the manifest
+type versionChange struct {
+	TemplatesFrom string
+	TemplatesTo   string
+	SpecKitFrom   string
+	SpecKitTo     string
+
+	templatesCmp int // Sign of TemplatesTo compared to TemplatesFrom
+	specKitCmp   int // Sign of SpecKitTo compared to SpecKitFrom
+}
+
+// IsCurrent returns true if the installed versions match the manifest
+func (c *versionChange) IsCurrent() bool {
+	return c.templatesCmp == 0 && c.specKitCmp == 0
+}
+
+// IsDowngrade returns true if either installed version is newer than the manifest
+func (c *versionChange) IsDowngrade() bool {
+	return c.templatesCmp < 0 || c.specKitCmp < 0
+}
+
+// String describes the components whose version changes
+func (c *versionChange) String() string {
+	changes := []string{}
+	if c.templatesCmp != 0 {
+		changes = append(changes, fmt.Sprintf("spec-kit-agents v%s → v%s", c.TemplatesFrom, c.TemplatesTo))
+	}
+	if c.specKitCmp != 0 {
+		changes = append(changes, fmt.Sprintf("spec-kit v%s → v%s", c.SpecKitFrom, c.SpecKitTo))
+	}
+	return strings.Join(changes, ", ")
+}
+
+// compareWithManifest compares the component versions in a version lock with
+// the versions the manifest would install
+func compareWithManifest(lock *models.VersionLock, manifest *models.Manifest) (*versionChange, error) {
+	change := &versionChange{}
+
+	templatesComp, err := lock.GetComponent("spec-kit-agents")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current spec-kit-agents version: %w", err)
+	}
+	change.TemplatesFrom = templatesComp.Version
+
+	specKitComp, err := lock.GetComponent("spec-kit")
 	if err != nil {
-		return false, "", fmt.Errorf("failed to get target spec-kit version: %w", err)
+		return nil, fmt.Errorf("failed to get current spec-kit version: %w", err)
 	}
+	change.SpecKitFrom = specKitComp.Version
 
-	// Compare versions
-	if currentSpecKitComp.Version == targetSpecKitVersion {
-		return false, fmt.Sprintf("already at latest version (v%s)", targetSpecKitVersion), nil
+	change.TemplatesTo, err = version.GetTemplatesVersion(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target spec-kit-agents version: %w", err)
 	}
 
-	cmp, err := version.CompareVersions(targetSpecKitVersion, currentSpecKitComp.Version)
+	change.SpecKitTo, err = version.GetSpecKitVersion(manifest)
 	if err != nil {
-		return false, "", fmt.Errorf("failed to compare versions: %w", err)
+		return nil, fmt.Errorf("failed to get target spec-kit version: %w", err)
 	}
 
-	if cmp > 0 {
-		return true, fmt.Sprintf("update available: v%s → v%s", currentSpecKitComp.Version, targetSpecKitVersion), nil
+	change.templatesCmp, err = version.CompareVersions(change.TemplatesTo, change.TemplatesFrom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare spec-kit-agents versions: %w", err)
+	}
+
+	change.specKitCmp, err = version.CompareVersions(change.SpecKitTo, change.SpecKitFrom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare spec-kit versions: %w", err)
 	}
 
-	return false, fmt.Sprintf("current version (v%s) is newer than manifest (v%s)", currentSpecKitComp.Version, targetSpecKitVersion), nil
+	return change, nil
 }
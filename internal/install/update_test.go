@@ -0,0 +1,225 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	"github.com/dkoenawan/claude-agent-templates/internal/version"
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+func TestCompareWithManifest(t *testing.T) {
+	tests := []struct {
+		name          string
+		templatesTo   string
+		specKitTo     string
+		wantCurrent   bool
+		wantDowngrade bool
+		wantString    string
+	}{
+		{
+			name:        "same versions",
+			templatesTo: "2.0.0",
+			specKitTo:   "0.0.72",
+			wantCurrent: true,
+		},
+		{
+			name:        "templates upgrade",
+			templatesTo: "2.1.0",
+			specKitTo:   "0.0.72",
+			wantString:  "spec-kit-agents v2.0.0 → v2.1.0",
+		},
+		{
+			name:        "both upgraded",
+			templatesTo: "v2.0.1",
+			specKitTo:   "0.0.80",
+			wantString:  "spec-kit-agents v2.0.0 → v2.0.1, spec-kit v0.0.72 → v0.0.80",
+		},
+		{
+			name:          "spec-kit downgrade",
+			templatesTo:   "2.1.0",
+			specKitTo:     "0.0.70",
+			wantDowngrade: true,
+			wantString:    "spec-kit-agents v2.0.0 → v2.1.0, spec-kit v0.0.72 → v0.0.70",
+		},
+		{
+			name:          "numeric rather than lexical comparison",
+			templatesTo:   "2.0.0",
+			specKitTo:     "0.0.8",
+			wantDowngrade: true,
+			wantString:    "spec-kit v0.0.72 → v0.0.8",
+		},
+	}
+
+	lock := version.CreateVersionLock("2.0.0", "0.0.72", "/tmp/spec-kit-agents")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &models.Manifest{
+				TemplatesVersion: tt.templatesTo,
+				Dependencies: map[string]models.Dependency{
+					"spec-kit": {Version: tt.specKitTo, Source: "vendored", InstallPath: ".specify"},
+				},
+			}
+
+			change, err := compareWithManifest(lock, manifest)
+			if err != nil {
+				t.Fatalf("compareWithManifest() error = %v", err)
+			}
+			if change.IsCurrent() != tt.wantCurrent {
+				t.Errorf("IsCurrent() = %v, want %v", change.IsCurrent(), tt.wantCurrent)
+			}
+			if change.IsDowngrade() != tt.wantDowngrade {
+				t.Errorf("IsDowngrade() = %v, want %v", change.IsDowngrade(), tt.wantDowngrade)
+			}
+			if got := change.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+// writeTestSource creates a source tree at the given templates version with
+// a vendored spec-kit that ships one command
+func writeTestSource(t *testing.T, templatesVersion, command string) string {
+	t.Helper()
+	root := t.TempDir()
+	manifest := `{
+  "version": "1.0",
+  "name": "spec-kit-agents",
+  "templates_version": "` + templatesVersion + `",
+  "dependencies": {
+    "spec-kit": {"version": "0.0.72", "source": "vendored", "install_path": ".specify"}
+  }
+}`
+	writeTestFile(t, filepath.Join(root, ".specify", "version-manifest.json"), manifest)
+	writeTestFile(t, filepath.Join(root, ".specify", "templates", "commands", "plan.md"), command)
+	writeTestFile(t, filepath.Join(root, "agents", "core", "cat-agent.md"), "# Agent\n")
+	return root
+}
+
+func TestUpdate_MergesCommandChangedUpstreamAndLocally(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+
+	base := "# Plan\none\ntwo\nthree\nfour\n"
+	if _, err := Run(Options{Prefix: prefix, SkipIntegrity: true, Source: writeTestSource(t, "2.0.0", base)}, logger); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := filepath.Join(paths.ClaudeCommands, "speckit.plan.md")
+	writeTestFile(t, command, "# Plan\none\nlocal\nthree\nfour\n")
+
+	source := writeTestSource(t, "2.1.0", "# Plan\none\ntwo\nthree\nupstream\n")
+	result, err := Update(prefix, UpdateOptions{Source: source, SkipIntegrity: true, SkipVerify: true}, logger)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if result.LocalChanges == nil || len(result.LocalChanges.Merged) != 1 || result.LocalChanges.Merged[0] != command {
+		t.Fatalf("Update() local changes = %+v, want %s merged", result.LocalChanges, command)
+	}
+	if got, want := readTestFile(t, command), "# Plan\none\nlocal\nthree\nupstream\n"; got != want {
+		t.Errorf("command content =\n%s\nwant\n%s", got, want)
+	}
+
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, ok := lock.GetFile(command)
+	if !ok {
+		t.Fatalf("%s not recorded in the version lock", command)
+	}
+	if want := ".specify/templates/commands/plan.md"; file.Source != want {
+		t.Errorf("command source = %q, want %q", file.Source, want)
+	}
+}
+
+func TestUpdate_RemovesCommandRemovedUpstream(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+
+	source := writeTestSource(t, "2.0.0", "# Plan\n")
+	writeTestFile(t, filepath.Join(source, ".specify", "templates", "commands", "tasks.md"), "# Tasks\n")
+	if _, err := Run(Options{Prefix: prefix, SkipIntegrity: true, Source: source}, logger); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := filepath.Join(paths.ClaudeCommands, "speckit.tasks.md")
+	if !config.PathExists(command) {
+		t.Fatalf("Run() did not install %s", command)
+	}
+
+	// The new release no longer ships the tasks command
+	if _, err := Update(prefix, UpdateOptions{Source: writeTestSource(t, "2.1.0", "# Plan\n"), SkipIntegrity: true, SkipVerify: true}, logger); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if config.PathExists(command) {
+		t.Errorf("Update() kept %s, which the new release no longer ships", command)
+	}
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lock.GetFile(command); ok {
+		t.Errorf("%s is still recorded in the version lock", command)
+	}
+}
+
+func TestUpdate_RecordsConflictSidecars(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	logger, _ := config.NewLogger(config.FATAL, "", false)
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+
+	if _, err := Run(Options{Prefix: prefix, SkipIntegrity: true, Source: writeTestSource(t, "2.0.0", "# Plan\none\n")}, logger); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	paths, err := GetPaths(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := filepath.Join(paths.ClaudeCommands, "speckit.plan.md")
+	writeTestFile(t, command, "# Plan\nlocal\n")
+
+	source := writeTestSource(t, "2.1.0", "# Plan\nupstream\n")
+	result, err := Update(prefix, UpdateOptions{Source: source, SkipIntegrity: true, SkipVerify: true}, logger)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if result.LocalChanges == nil || len(result.LocalChanges.Sidecars) != 2 {
+		t.Fatalf("Update() local changes = %+v, want two sidecars", result.LocalChanges)
+	}
+
+	lock, err := version.LoadVersionLockFromPath(paths.VersionLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sidecar := range []string{command + SidecarNewSuffix, command + SidecarOrigSuffix} {
+		file, ok := lock.GetFile(sidecar)
+		if !ok {
+			t.Errorf("%s not recorded in the version lock", sidecar)
+			continue
+		}
+		if hash, _, err := HashFile(sidecar); err != nil || hash != file.SHA256 {
+			t.Errorf("%s recorded with hash %s, file has %s (%v)", sidecar, file.SHA256, hash, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package merge implements a line-based three-way merge (diff3) used to carry
+// local modifications of installed files across updates.
+package merge
+
+import (
+	"bytes"
+)
+
+// maxMatrixCells bounds the size of the longest-common-subsequence table.
+// Larger inputs are merged as a single change region.
+const maxMatrixCells = 16 * 1024 * 1024
+
+// Labels names the three versions in conflict markers
+type Labels struct {
+	Ours   string
+	Base   string
+	Theirs string
+}
+
+// DefaultLabels are used when no labels are given
+var DefaultLabels = Labels{
+	Ours:   "local",
+	Base:   "base",
+	Theirs: "upstream",
+}
+
+// Result contains the outcome of a three-way merge
+type Result struct {
+	Content   []byte // Merged content, with conflict markers if Conflicts > 0
+	Conflicts int    // Number of conflicting regions
+}
+
+// HasConflicts returns true if the merge could not be resolved automatically
+func (r *Result) HasConflicts() bool {
+	return r.Conflicts > 0
+}
+
+// ThreeWay merges the changes from base to ours and from base to theirs.
+//
+// Regions changed on only one side take that side's version. Regions changed
+// identically on both sides are taken once. Regions changed differently on
+// both sides are conflicts and are written with diff3-style markers.
+func ThreeWay(base, ours, theirs []byte, labels Labels) *Result {
+	if labels == (Labels{}) {
+		labels = DefaultLabels
+	}
+
+	baseLines := splitLines(base)
+	oursLines := splitLines(ours)
+	theirsLines := splitLines(theirs)
+
+	matchOurs := matchLines(baseLines, oursLines)
+	matchTheirs := matchLines(baseLines, theirsLines)
+
+	result := &Result{}
+	var out bytes.Buffer
+
+	i0, i1, i2 := 0, 0, 0
+	for i0 < len(baseLines) || i1 < len(oursLines) || i2 < len(theirsLines) {
+		// Stable region: base lines matched consecutively on both sides
+		j := 0
+		for i0+j < len(baseLines) &&
+			matchOurs[i0+j] == i1+j &&
+			matchTheirs[i0+j] == i2+j {
+			j++
+		}
+		if j > 0 {
+			writeLines(&out, baseLines[i0:i0+j])
+			i0, i1, i2 = i0+j, i1+j, i2+j
+			continue
+		}
+
+		// Unstable region: runs until the next base line matched on both sides
+		o := i0
+		for o < len(baseLines) && (matchOurs[o] < 0 || matchTheirs[o] < 0) {
+			o++
+		}
+
+		a, b := len(oursLines), len(theirsLines)
+		if o < len(baseLines) {
+			a, b = matchOurs[o], matchTheirs[o]
+		}
+
+		resolveRegion(&out, result, labels,
+			baseLines[i0:o], oursLines[i1:a], theirsLines[i2:b])
+
+		i0, i1, i2 = o, a, b
+	}
+
+	result.Content = out.Bytes()
+	return result
+}
+
+// resolveRegion writes the merged form of one changed region
+func resolveRegion(out *bytes.Buffer, result *Result, labels Labels, base, ours, theirs [][]byte) {
+	switch {
+	case equalLines(ours, base):
+		writeLines(out, theirs)
+	case equalLines(theirs, base), equalLines(ours, theirs):
+		writeLines(out, ours)
+	default:
+		result.Conflicts++
+		out.WriteString("<<<<<<< " + labels.Ours + "\n")
+		writeSection(out, ours)
+		out.WriteString("||||||| " + labels.Base + "\n")
+		writeSection(out, base)
+		out.WriteString("=======\n")
+		writeSection(out, theirs)
+		out.WriteString(">>>>>>> " + labels.Theirs + "\n")
+	}
+}
+
+// matchLines returns, for each line of a, the index of the line of b it is
+// paired with in a longest common subsequence, or -1 if it is not paired.
+// Pairings are strictly increasing in both a and b.
+func matchLines(a, b [][]byte) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// Common prefix and suffix are paired directly
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		match[prefix] = prefix
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		match[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if n == 0 || m == 0 || n*m > maxMatrixCells {
+		return match
+	}
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if bytes.Equal(midA[i], midB[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case bytes.Equal(midA[i], midB[j]):
+			match[prefix+i] = prefix + j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return match
+}
+
+// splitLines splits content into lines, keeping line endings
+func splitLines(content []byte) [][]byte {
+	lines := [][]byte{}
+	for len(content) > 0 {
+		end := bytes.IndexByte(content, '\n')
+		if end < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:end+1])
+		content = content[end+1:]
+	}
+	return lines
+}
+
+func equalLines(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *bytes.Buffer, lines [][]byte) {
+	for _, line := range lines {
+		out.Write(line)
+	}
+}
+
+// writeSection writes the lines of a conflict section, making sure the
+// section ends with a newline so the following marker starts on its own line
+func writeSection(out *bytes.Buffer, lines [][]byte) {
+	writeLines(out, lines)
+	if len(lines) > 0 {
+		last := lines[len(lines)-1]
+		if last[len(last)-1] != '\n' {
+			out.WriteByte('\n')
+		}
+	}
+}
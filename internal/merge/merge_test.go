@@ -0,0 +1,117 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+)
+
+func lines(l ...string) string {
+	return strings.Join(l, "\n") + "\n"
+}
+
+func TestThreeWay(t *testing.T) {
+	base := lines("title", "one", "two", "three", "four", "five")
+
+	tests := []struct {
+		name          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name:   "no changes",
+			ours:   base,
+			theirs: base,
+			want:   base,
+		},
+		{
+			name:   "only ours changed",
+			ours:   lines("title", "one", "TWO", "three", "four", "five"),
+			theirs: base,
+			want:   lines("title", "one", "TWO", "three", "four", "five"),
+		},
+		{
+			name:   "only theirs changed",
+			ours:   base,
+			theirs: lines("title", "one", "two", "three", "FOUR", "five"),
+			want:   lines("title", "one", "two", "three", "FOUR", "five"),
+		},
+		{
+			name:   "non-overlapping changes",
+			ours:   lines("title", "one", "TWO", "three", "four", "five"),
+			theirs: lines("title", "one", "two", "three", "FOUR", "five", "six"),
+			want:   lines("title", "one", "TWO", "three", "FOUR", "five", "six"),
+		},
+		{
+			name:   "identical changes on both sides",
+			ours:   lines("title", "one", "2", "three", "four", "five"),
+			theirs: lines("title", "one", "2", "three", "four", "five"),
+			want:   lines("title", "one", "2", "three", "four", "five"),
+		},
+		{
+			name:   "ours inserts, theirs deletes elsewhere",
+			ours:   lines("title", "intro", "one", "two", "three", "four", "five"),
+			theirs: lines("title", "one", "two", "three", "five"),
+			want:   lines("title", "intro", "one", "two", "three", "five"),
+		},
+		{
+			name:          "conflicting changes",
+			ours:          lines("title", "one", "local", "three", "four", "five"),
+			theirs:        lines("title", "one", "upstream", "three", "four", "five"),
+			wantConflicts: 1,
+			want: lines("title", "one",
+				"<<<<<<< local", "local",
+				"||||||| base", "two",
+				"=======", "upstream",
+				">>>>>>> upstream",
+				"three", "four", "five"),
+		},
+		{
+			name:          "conflicting appends",
+			ours:          lines("title", "one", "two", "three", "four", "five", "mine"),
+			theirs:        lines("title", "one", "two", "three", "four", "five", "theirs"),
+			wantConflicts: 1,
+			want: lines("title", "one", "two", "three", "four", "five",
+				"<<<<<<< local", "mine",
+				"||||||| base",
+				"=======", "theirs",
+				">>>>>>> upstream"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ThreeWay([]byte(base), []byte(tt.ours), []byte(tt.theirs), Labels{})
+			if got.Conflicts != tt.wantConflicts {
+				t.Errorf("ThreeWay() conflicts = %d, want %d", got.Conflicts, tt.wantConflicts)
+			}
+			if string(got.Content) != tt.want {
+				t.Errorf("ThreeWay() content =\n%s\nwant\n%s", got.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreeWay_MissingTrailingNewline(t *testing.T) {
+	base := "a\nb"
+	ours := "a\nours"
+	theirs := "a\ntheirs"
+
+	got := ThreeWay([]byte(base), []byte(ours), []byte(theirs), Labels{Ours: "o", Base: "b", Theirs: "t"})
+	if !got.HasConflicts() {
+		t.Fatal("ThreeWay() expected a conflict")
+	}
+
+	want := "a\n<<<<<<< o\nours\n||||||| b\nb\n=======\ntheirs\n>>>>>>> t\n"
+	if string(got.Content) != want {
+		t.Errorf("ThreeWay() content =\n%q\nwant\n%q", got.Content, want)
+	}
+}
+
+func TestThreeWay_EmptyBase(t *testing.T) {
+	got := ThreeWay(nil, []byte("same\n"), []byte("same\n"), Labels{})
+	if got.HasConflicts() || string(got.Content) != "same\n" {
+		t.Errorf("ThreeWay() = %q (conflicts %d), want %q", got.Content, got.Conflicts, "same\n")
+	}
+}
@@ -0,0 +1,15 @@
+package version
+
+import "errors"
+
+// Failure classes returned by version checks. They are wrapped with details,
+// so test for them with errors.Is.
+var (
+	// ErrIncompatible is returned when a spec-kit version is outside the
+	// range supported by the manifest
+	ErrIncompatible = errors.New("version incompatible")
+
+	// ErrIntegrityMismatch is returned when files do not match the integrity
+	// hash pinned in the manifest
+	ErrIntegrityMismatch = errors.New("integrity mismatch")
+)
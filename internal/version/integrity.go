@@ -0,0 +1,200 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+// integrityPrefix is the algorithm prefix used in manifest integrity values
+const integrityPrefix = "sha256-"
+
+// manifestFileName is excluded from tree hashes, since the manifest stores
+// the hash of the tree it lives in
+const manifestFileName = "version-manifest.json"
+
+// ComputeTreeHash computes a deterministic hash of a directory tree in the
+// manifest integrity format (sha256-[64 hex chars]).
+//
+// Files are visited in lexical order and each contributes its slash-separated
+// relative path and the SHA-256 of its content. The version manifest at the
+// root of the tree and any .git directories are excluded.
+func ComputeTreeHash(dir string) (string, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("directory not found: %s", dir)
+	}
+
+	hash, err := ComputeFSTreeHash(os.DirFS(dir))
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", dir, err)
+	}
+
+	return hash, nil
+}
+
+// ComputeFSTreeHash computes the tree hash of a filesystem, such as the
+// payload embedded in the binary, in the same way as ComputeTreeHash
+func ComputeFSTreeHash(fsys fs.FS) (string, error) {
+	tree := sha256.New()
+
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if path == manifestFileName {
+			return nil
+		}
+
+		var digest string
+		if entry.Type()&fs.ModeSymlink != 0 {
+			target, err := fs.ReadLink(fsys, path)
+			if err != nil {
+				return err
+			}
+			digest = "symlink:" + filepath.ToSlash(target)
+		} else {
+			digest, err = hashFSContent(fsys, path)
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(tree, "%s\x00%s\n", path, digest)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return integrityPrefix + hex.EncodeToString(tree.Sum(nil)), nil
+}
+
+// VerifyIntegrity checks that the tree hash of dir matches the expected
+// integrity value from the manifest
+func VerifyIntegrity(dir, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("no integrity hash specified")
+	}
+
+	actual, err := ComputeTreeHash(dir)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w for %s: expected %s, got %s", ErrIntegrityMismatch, dir, expected, actual)
+	}
+
+	return nil
+}
+
+// VerifyFSIntegrity checks that the tree hash of a filesystem matches the
+// expected integrity value. name identifies the files in errors.
+func VerifyFSIntegrity(fsys fs.FS, name, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("no integrity hash specified")
+	}
+
+	actual, err := ComputeFSTreeHash(fsys)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", name, err)
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w for %s: expected %s, got %s", ErrIntegrityMismatch, name, expected, actual)
+	}
+
+	return nil
+}
+
+// ComputeFileHash computes the hash of a single file, such as a release
+// archive, in the manifest integrity format (sha256-[64 hex chars])
+func ComputeFileHash(path string) (string, error) {
+	digest, err := hashFileContent(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return integrityPrefix + digest, nil
+}
+
+// VerifyFileIntegrity checks that the hash of a file matches the expected
+// integrity value from the manifest
+func VerifyFileIntegrity(path, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("no integrity hash specified")
+	}
+
+	actual, err := ComputeFileHash(path)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w for %s: expected %s, got %s", ErrIntegrityMismatch, path, expected, actual)
+	}
+
+	return nil
+}
+
+// VerifySpecKitIntegrity checks the spec-kit source directory against the
+// integrity hash pinned in the manifest. A manifest without an integrity hash
+// cannot be verified and is reported as such.
+func VerifySpecKitIntegrity(manifest *models.Manifest, dir string) error {
+	dep, err := manifest.GetSpecKitDependency()
+	if err != nil {
+		return err
+	}
+
+	if dep.Integrity == "" {
+		return fmt.Errorf("manifest does not pin an integrity hash for spec-kit")
+	}
+
+	return VerifyIntegrity(dir, dep.Integrity)
+}
+
+// hashFileContent returns the hex-encoded SHA-256 of a file's content
+func hashFileContent(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// hashFSContent returns the hex-encoded SHA-256 of a file in a filesystem
+func hashFSContent(fsys fs.FS, path string) (string, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
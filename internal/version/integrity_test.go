@@ -0,0 +1,136 @@
+package version
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestComputeTreeHash(t *testing.T) {
+	files := map[string]string{
+		"templates/spec-template.md": "spec",
+		"memory/constitution.md":     "constitution",
+		"scripts/bash/common.sh":     "#!/bin/bash",
+	}
+
+	dirA := t.TempDir()
+	writeTree(t, dirA, files)
+	dirB := t.TempDir()
+	writeTree(t, dirB, files)
+
+	hashA, err := ComputeTreeHash(dirA)
+	if err != nil {
+		t.Fatalf("ComputeTreeHash() error = %v", err)
+	}
+	if !strings.HasPrefix(hashA, "sha256-") || len(hashA) != len("sha256-")+64 {
+		t.Errorf("ComputeTreeHash() = %s, want sha256-[64 hex chars]", hashA)
+	}
+
+	hashB, err := ComputeTreeHash(dirB)
+	if err != nil {
+		t.Fatalf("ComputeTreeHash() error = %v", err)
+	}
+	if hashA != hashB {
+		t.Errorf("identical trees hashed differently: %s vs %s", hashA, hashB)
+	}
+
+	// The manifest and .git directories do not affect the hash
+	writeTree(t, dirB, map[string]string{
+		"version-manifest.json": `{"version": "1.0"}`,
+		".git/HEAD":             "ref: refs/heads/main",
+	})
+	if hashB, _ = ComputeTreeHash(dirB); hashA != hashB {
+		t.Errorf("manifest or .git changed the hash: %s vs %s", hashA, hashB)
+	}
+
+	// Content changes do
+	writeTree(t, dirB, map[string]string{"memory/constitution.md": "edited"})
+	if hashB, _ = ComputeTreeHash(dirB); hashA == hashB {
+		t.Error("content change did not change the hash")
+	}
+
+	// So do renames
+	dirC := t.TempDir()
+	writeTree(t, dirC, map[string]string{
+		"templates/spec-template.md": "spec",
+		"memory/constitution.md":     "constitution",
+		"scripts/bash/renamed.sh":    "#!/bin/bash",
+	})
+	if hashC, _ := ComputeTreeHash(dirC); hashA == hashC {
+		t.Error("rename did not change the hash")
+	}
+}
+
+func TestComputeTreeHash_MissingDirectory(t *testing.T) {
+	if _, err := ComputeTreeHash(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ComputeTreeHash() expected error for missing directory")
+	}
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"templates/spec-template.md": "spec"})
+
+	hash, err := ComputeTreeHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "matching hash", expected: hash},
+		{name: "placeholder hash", expected: "sha256-" + strings.Repeat("0", 64), wantErr: true},
+		{name: "empty hash", expected: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyIntegrity(dir, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyIntegrity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyFileIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"spec-kit.tar.gz": "archive"})
+	path := filepath.Join(dir, "spec-kit.tar.gz")
+
+	hash, err := ComputeFileHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sha256-0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"; hash != want {
+		t.Errorf("ComputeFileHash() = %s, want %s", hash, want)
+	}
+
+	if err := VerifyFileIntegrity(path, hash); err != nil {
+		t.Errorf("VerifyFileIntegrity() error = %v", err)
+	}
+	if err := VerifyFileIntegrity(path, "sha256-"+strings.Repeat("0", 64)); !errors.Is(err, ErrIntegrityMismatch) {
+		t.Errorf("VerifyFileIntegrity() error = %v, want ErrIntegrityMismatch", err)
+	}
+	if err := VerifyFileIntegrity(path, ""); err == nil {
+		t.Error("VerifyFileIntegrity() expected error for empty hash")
+	}
+}
@@ -2,6 +2,8 @@ package version
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/dkoenawan/claude-agent-templates/internal/config"
 	"github.com/dkoenawan/claude-agent-templates/pkg/models"
@@ -31,6 +33,44 @@ func GetSpecKitVersion(manifest *models.Manifest) (string, error) {
 	return dep.Version, nil
 }
 
+// BuildVersion is the spec-kit-agents version embedded at build time, used
+// when the manifest does not declare templates_version. Set with:
+//
+//	-ldflags "-X github.com/dkoenawan/claude-agent-templates/internal/version.BuildVersion=v2.1.0"
+var BuildVersion = ""
+
+// releaseVersionPattern matches the X.Y.Z versions recorded in the version lock
+var releaseVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+// GetTemplatesVersion determines the spec-kit-agents version being installed:
+// templates_version from the manifest if present, otherwise BuildVersion.
+// A leading "v" (as in git tags) is removed; the result must be X.Y.Z.
+func GetTemplatesVersion(manifest *models.Manifest) (string, error) {
+	if manifest.TemplatesVersion != "" {
+		return NormalizeVersion(manifest.TemplatesVersion)
+	}
+
+	if BuildVersion != "" {
+		v, err := NormalizeVersion(BuildVersion)
+		if err != nil {
+			return "", fmt.Errorf("invalid build version: %w", err)
+		}
+		return v, nil
+	}
+
+	return "", fmt.Errorf("templates version unknown: set templates_version in the manifest or build with version.BuildVersion")
+}
+
+// NormalizeVersion removes a leading "v" from a version and checks that the
+// result is a plain X.Y.Z version
+func NormalizeVersion(v string) (string, error) {
+	normalized := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if !releaseVersionPattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid version format: %s (expected X.Y.Z)", v)
+	}
+	return normalized, nil
+}
+
 // GetSpecKitCompatibility retrieves the compatibility constraints for spec-kit
 func GetSpecKitCompatibility(manifest *models.Manifest) (*models.Compatibility, error) {
 	dep, err := manifest.GetSpecKitDependency()
@@ -0,0 +1,66 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/dkoenawan/claude-agent-templates/pkg/models"
+)
+
+func TestGetTemplatesVersion(t *testing.T) {
+	tests := []struct {
+		name             string
+		templatesVersion string
+		buildVersion     string
+		want             string
+		wantErr          bool
+	}{
+		{name: "from manifest", templatesVersion: "2.1.0", buildVersion: "v9.9.9", want: "2.1.0"},
+		{name: "manifest with v prefix", templatesVersion: "v2.1.0", want: "2.1.0"},
+		{name: "from build version", buildVersion: "v2.2.0", want: "2.2.0"},
+		{name: "invalid build version", buildVersion: "dev", wantErr: true},
+		{name: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := BuildVersion
+			BuildVersion = tt.buildVersion
+			defer func() { BuildVersion = saved }()
+
+			manifest := &models.Manifest{TemplatesVersion: tt.templatesVersion}
+			got, err := GetTemplatesVersion(manifest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetTemplatesVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetTemplatesVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "2.0.0", want: "2.0.0"},
+		{input: "v2.0.0", want: "2.0.0"},
+		{input: "2.0", wantErr: true},
+		{input: "v2.0.0-beta.1", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeVersion(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+// Package claudeagenttemplates embeds the agent templates and the vendored
+// spec-kit files, so a spec-kit-agents binary can install the payload it was
+// built with without a repository checkout.
+package claudeagenttemplates
+
+import "embed"
+
+// Payload holds agents/ and .specify/ as they were at build time. The all:
+// prefix keeps dotfiles, so the embedded .specify/ hashes the same as the
+// directory the manifest integrity hash was computed from.
+//
+//go:embed all:agents all:.specify
+var Payload embed.FS
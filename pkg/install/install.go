@@ -0,0 +1,80 @@
+// Package install installs, updates, rolls back and uninstalls
+// spec-kit-agents from another program. Every function logs through the
+// *slog.Logger it is given, so the program's own handler receives the
+// messages the CLI would print.
+//
+// Each record has a "component" attribute naming the step that logged it,
+// such as "installer" or "update", and an "operation_id" attribute shared by
+// every record of one call. Completed steps are logged at LevelSuccess, which
+// ranks between INFO and WARN.
+package install
+
+import (
+	"log/slog"
+
+	"github.com/dkoenawan/claude-agent-templates/internal/config"
+	internalinstall "github.com/dkoenawan/claude-agent-templates/internal/install"
+)
+
+// LevelSuccess is the level of the records that report a completed step
+const LevelSuccess = config.LevelSuccess
+
+// Options, results and policies of the operations
+type (
+	Options            = internalinstall.Options
+	InstallationResult = internalinstall.InstallationResult
+	UpdateOptions      = internalinstall.UpdateOptions
+	UpdateResult       = internalinstall.UpdateResult
+	ConflictStrategy   = internalinstall.ConflictStrategy
+	RetentionPolicy    = internalinstall.RetentionPolicy
+	RollbackOptions    = internalinstall.RollbackOptions
+	RollbackResult     = internalinstall.RollbackResult
+	UninstallOptions   = internalinstall.UninstallOptions
+	UninstallResult    = internalinstall.UninstallResult
+)
+
+// What update does with local changes that cannot be merged
+const (
+	ConflictSidecar = internalinstall.ConflictSidecar
+	ConflictMarkers = internalinstall.ConflictMarkers
+	ConflictAbort   = internalinstall.ConflictAbort
+)
+
+// Errors the operations return, to be checked with errors.Is
+var (
+	ErrNotInstalled     = internalinstall.ErrNotInstalled
+	ErrAlreadyInstalled = internalinstall.ErrAlreadyInstalled
+	ErrBackupNotFound   = internalinstall.ErrBackupNotFound
+	ErrBackupCorrupt    = internalinstall.ErrBackupCorrupt
+	ErrDriftDetected    = internalinstall.ErrDriftDetected
+	ErrConflicts        = internalinstall.ErrConflicts
+	ErrLocked           = internalinstall.ErrLocked
+)
+
+// Install installs spec-kit-agents as described by opts
+func Install(opts Options, logger *slog.Logger) (*InstallationResult, error) {
+	return internalinstall.Run(opts, newLogger(logger))
+}
+
+// Update updates the installation at prefix
+func Update(prefix string, opts UpdateOptions, logger *slog.Logger) (*UpdateResult, error) {
+	return internalinstall.Update(prefix, opts, newLogger(logger))
+}
+
+// Rollback restores the installation at prefix from one of its backups
+func Rollback(prefix string, opts RollbackOptions, logger *slog.Logger) (*RollbackResult, error) {
+	return internalinstall.Rollback(prefix, opts, newLogger(logger))
+}
+
+// Uninstall removes the installation at prefix
+func Uninstall(prefix string, opts UninstallOptions, logger *slog.Logger) (*UninstallResult, error) {
+	return internalinstall.Uninstall(prefix, opts, newLogger(logger))
+}
+
+// newLogger logs an operation through logger, or slog.Default if it is nil
+func newLogger(logger *slog.Logger) internalinstall.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return config.NewHandlerLogger(logger.Handler())
+}
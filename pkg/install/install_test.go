@@ -0,0 +1,59 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstall_LogsThroughSlog(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	source := t.TempDir()
+	manifest := `{"version": "1.0", "name": "spec-kit-agents", "templates_version": "2.0.0",
+  "dependencies": {"spec-kit": {"version": "0.0.72", "source": "vendored", "install_path": ".specify"}}}`
+	for _, dir := range []string{".specify", "agents"} {
+		if err := os.MkdirAll(filepath.Join(source, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(source, ".specify", "version-manifest.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	prefix := filepath.Join(t.TempDir(), "spec-kit-agents")
+
+	if _, err := Install(Options{Prefix: prefix, DryRun: true, Source: source}, logger); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var record struct {
+		Component   string `json:"component"`
+		OperationID string `json:"operation_id"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("handler received %q: %v", lines[0], err)
+	}
+	if record.Component != "installer" || record.OperationID == "" {
+		t.Errorf("record = %+v, want the installer component and an operation ID", record)
+	}
+}
+
+func TestUninstall_NotInstalled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	_, err := Uninstall(filepath.Join(t.TempDir(), "spec-kit-agents"), UninstallOptions{}, slog.New(slog.DiscardHandler))
+	if !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Uninstall() error = %v, want ErrNotInstalled", err)
+	}
+}
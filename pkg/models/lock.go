@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
@@ -10,6 +11,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// lockBackupSuffix names the previous generation of a version lock, kept
+// next to it so that a damaged lock can be recovered
+const lockBackupSuffix = ".bak"
+
 // VersionLock represents the installed component versions and history
 type VersionLock struct {
 	Version        string              `json:"version"`
@@ -17,7 +22,27 @@ type VersionLock struct {
 	InstalledAt    string              `json:"installed_at"`
 	LastVerified   string              `json:"last_verified,omitempty"`
 	Components     map[string]Component `json:"components"`
+	Files          []OwnedFile         `json:"files,omitempty"`
 	History        []HistoryEntry      `json:"history,omitempty"`
+
+	// Recovered is set when the lock file was damaged and this is its
+	// previous generation, until the lock is saved again
+	Recovered *LockRecoveredError `json:"-"`
+}
+
+// LockRecoveredError reports that a version lock could not be used and its
+// previous generation was loaded in its place
+type LockRecoveredError struct {
+	Path string // The damaged version lock
+	Err  error  // Why it could not be used
+}
+
+func (e *LockRecoveredError) Error() string {
+	return fmt.Sprintf("version lock %s is damaged (%v); using its previous generation from %s", e.Path, e.Err, VersionLockBackupPath(e.Path))
+}
+
+func (e *LockRecoveredError) Unwrap() error {
+	return e.Err
 }
 
 // Component represents an installed component
@@ -28,21 +53,30 @@ type Component struct {
 	InstallPath   string `json:"install_path"`
 }
 
+// OwnedFile represents a file written by the installer
+type OwnedFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Source string `json:"source,omitempty"`
+}
+
 // HistoryEntry represents a single installation/upgrade event
 type HistoryEntry struct {
-	Timestamp string `json:"timestamp"`
-	Action    string `json:"action"`
-	Component string `json:"component"`
-	Version   string `json:"version,omitempty"`
-	Status    string `json:"status"`
-	Error     string `json:"error,omitempty"`
+	Timestamp   string `json:"timestamp"`
+	Action      string `json:"action"`
+	Component   string `json:"component"`
+	Version     string `json:"version,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	OperationID string `json:"operation_id,omitempty"` // Matches the install log entries of the command
 }
 
 // NewVersionLock creates a new version lock with a unique installation ID
 func NewVersionLock() *VersionLock {
 	now := time.Now().UTC().Format(time.RFC3339)
 	return &VersionLock{
-		Version:        "1.0",
+		Version:        CurrentLockVersion,
 		InstallationID: uuid.New().String(),
 		InstalledAt:    now,
 		LastVerified:   now,
@@ -51,13 +85,59 @@ func NewVersionLock() *VersionLock {
 	}
 }
 
-// LoadVersionLock loads a version lock from a JSON file
+// VersionLockBackupPath returns the path of the previous generation of the
+// version lock at path
+func VersionLockBackupPath(path string) string {
+	return path + lockBackupSuffix
+}
+
+// LoadVersionLock loads a version lock from a JSON file. A lock with an
+// older schema is migrated in memory only: loading never writes it, so that
+// read-only commands cannot race an install. The next Save, which commands
+// only call while holding the operation lock, writes the migrated lock and
+// keeps the original as its backup. If the file is damaged, its previous
+// generation is loaded from the backup instead, with Recovered naming the
+// damaged file; it replaces the damaged file on the next Save.
 func LoadVersionLock(path string) (*VersionLock, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read version lock: %w", err)
 	}
 
+	lock, err := parseVersionLock(data)
+	if err == nil {
+		return lock, nil
+	}
+
+	// A lock from a newer release is intact; replacing it with an older
+	// generation would lose its changes
+	var unsupported *UnsupportedLockVersionError
+	if errors.As(err, &unsupported) {
+		return nil, err
+	}
+
+	// Fall back to the previous generation, reporting the original problem
+	// if there is none
+	backupData, readErr := os.ReadFile(VersionLockBackupPath(path))
+	if readErr != nil {
+		return nil, err
+	}
+	backup, backupErr := parseVersionLock(backupData)
+	if backupErr != nil {
+		return nil, err
+	}
+
+	backup.Recovered = &LockRecoveredError{Path: path, Err: err}
+	return backup, nil
+}
+
+// parseVersionLock migrates, parses and validates a version lock
+func parseVersionLock(data []byte) (*VersionLock, error) {
+	data, err := migrateVersionLock(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var lock VersionLock
 	if err := json.Unmarshal(data, &lock); err != nil {
 		return nil, fmt.Errorf("failed to parse version lock JSON: %w", err)
@@ -71,17 +151,27 @@ func LoadVersionLock(path string) (*VersionLock, error) {
 	return &lock, nil
 }
 
-// Save saves the version lock to a JSON file
+// Save saves the version lock to a JSON file. The write is atomic, and the
+// file it replaces is kept as a backup if it is a valid version lock.
 func (vl *VersionLock) Save(path string) error {
 	data, err := json.MarshalIndent(vl, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal version lock: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if previous, err := os.ReadFile(path); err == nil {
+		if _, err := parseVersionLock(previous); err == nil {
+			if err := WriteFileAtomic(VersionLockBackupPath(path), previous, 0644); err != nil {
+				return fmt.Errorf("failed to back up version lock: %w", err)
+			}
+		}
+	}
+
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write version lock: %w", err)
 	}
 
+	vl.Recovered = nil
 	return nil
 }
 
@@ -121,11 +211,21 @@ func (vl *VersionLock) Validate() error {
 		}
 	}
 
-	// Validate history entries
+	// Validate owned files
+	for i, file := range vl.Files {
+		if err := file.Validate(); err != nil {
+			return fmt.Errorf("file entry %d: %w", i, err)
+		}
+	}
+
+	// Validate history entries, which name a recorded component or all of them
 	for i, entry := range vl.History {
 		if err := entry.Validate(); err != nil {
 			return fmt.Errorf("history entry %d: %w", i, err)
 		}
+		if _, exists := vl.Components[entry.Component]; !exists && entry.Component != "all" {
+			return fmt.Errorf("history entry %d: invalid component: %s", i, entry.Component)
+		}
 	}
 
 	return nil
@@ -161,6 +261,24 @@ func (c *Component) Validate(name string) error {
 	return nil
 }
 
+// Validate checks if an owned file entry is valid
+func (f *OwnedFile) Validate() error {
+	if f.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	if f.Size < 0 {
+		return fmt.Errorf("invalid size for %s: %d", f.Path, f.Size)
+	}
+
+	sha256Pattern := regexp.MustCompile(`^[a-f0-9]{64}$`)
+	if !sha256Pattern.MatchString(f.SHA256) {
+		return fmt.Errorf("invalid sha256 for %s: %s (expected 64 hex chars)", f.Path, f.SHA256)
+	}
+
+	return nil
+}
+
 // Validate checks if a history entry is valid
 func (he *HistoryEntry) Validate() error {
 	// Validate timestamp
@@ -169,15 +287,14 @@ func (he *HistoryEntry) Validate() error {
 	}
 
 	// Validate action
-	validActions := map[string]bool{"install": true, "upgrade": true, "verify": true, "rollback": true}
+	validActions := map[string]bool{"install": true, "upgrade": true, "verify": true, "rollback": true, "uninstall": true}
 	if !validActions[he.Action] {
-		return fmt.Errorf("invalid action: %s (must be install, upgrade, verify, or rollback)", he.Action)
+		return fmt.Errorf("invalid action: %s (must be install, upgrade, verify, rollback, or uninstall)", he.Action)
 	}
 
-	// Validate component
-	validComponents := map[string]bool{"claude-agent-templates": true, "spec-kit": true, "all": true}
-	if !validComponents[he.Component] {
-		return fmt.Errorf("invalid component: %s", he.Component)
+	// Validate component; VersionLock.Validate checks that it was installed
+	if he.Component == "" {
+		return fmt.Errorf("component is required")
 	}
 
 	// Validate version if present
@@ -235,3 +352,31 @@ func (vl *VersionLock) SetComponent(name string, comp Component) {
 	}
 	vl.Components[name] = comp
 }
+
+// AddFiles records files written by the installer, replacing any existing
+// entry for the same path
+func (vl *VersionLock) AddFiles(files []OwnedFile) {
+	index := make(map[string]int, len(vl.Files))
+	for i, file := range vl.Files {
+		index[file.Path] = i
+	}
+
+	for _, file := range files {
+		if i, exists := index[file.Path]; exists {
+			vl.Files[i] = file
+			continue
+		}
+		index[file.Path] = len(vl.Files)
+		vl.Files = append(vl.Files, file)
+	}
+}
+
+// GetFile returns the ownership entry for a path, if the installer wrote it
+func (vl *VersionLock) GetFile(path string) (*OwnedFile, bool) {
+	for i := range vl.Files {
+		if vl.Files[i].Path == path {
+			return &vl.Files[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOwnedFile_Validate(t *testing.T) {
+	validHash := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name    string
+		file    OwnedFile
+		wantErr bool
+	}{
+		{
+			name: "valid file",
+			file: OwnedFile{Path: "/home/user/.claude/agents/cat-documentation.md", Size: 10, SHA256: validHash},
+		},
+		{
+			name:    "missing path",
+			file:    OwnedFile{Size: 10, SHA256: validHash},
+			wantErr: true,
+		},
+		{
+			name:    "negative size",
+			file:    OwnedFile{Path: "/tmp/file", Size: -1, SHA256: validHash},
+			wantErr: true,
+		},
+		{
+			name:    "invalid hash",
+			file:    OwnedFile{Path: "/tmp/file", Size: 10, SHA256: "not-a-hash"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.file.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OwnedFile.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVersionLock_AddFiles(t *testing.T) {
+	lock := NewVersionLock()
+
+	lock.AddFiles([]OwnedFile{
+		{Path: "/a", Size: 1, SHA256: strings.Repeat("1", 64)},
+		{Path: "/b", Size: 2, SHA256: strings.Repeat("2", 64)},
+	})
+	lock.AddFiles([]OwnedFile{
+		{Path: "/a", Size: 3, SHA256: strings.Repeat("3", 64)},
+	})
+
+	if len(lock.Files) != 2 {
+		t.Fatalf("len(Files) = %d, want 2", len(lock.Files))
+	}
+
+	file, ok := lock.GetFile("/a")
+	if !ok {
+		t.Fatal("GetFile(/a) not found")
+	}
+	if file.Size != 3 {
+		t.Errorf("GetFile(/a).Size = %d, want 3 (latest entry)", file.Size)
+	}
+
+	if _, ok := lock.GetFile("/missing"); ok {
+		t.Error("GetFile(/missing) unexpectedly found")
+	}
+}
+
+func TestVersionLock_SaveAndLoadFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".version-lock.json")
+
+	lock := NewVersionLock()
+	lock.SetComponent("spec-kit", Component{Version: "0.0.72", InstalledFrom: "vendored", InstallPath: "/tmp/.specify"})
+	lock.AddFiles([]OwnedFile{{Path: "/tmp/.specify/a.md", Size: 4, SHA256: strings.Repeat("f", 64), Source: ".specify/a.md"}})
+
+	if err := lock.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadVersionLock(path)
+	if err != nil {
+		t.Fatalf("LoadVersionLock() error = %v", err)
+	}
+	if len(loaded.Files) != 1 || loaded.Files[0].Source != ".specify/a.md" {
+		t.Errorf("loaded Files = %+v, want one entry with source", loaded.Files)
+	}
+}
+
+func TestLoadVersionLock_RecoversFromBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".version-lock.json")
+
+	lock := NewVersionLock()
+	lock.SetComponent("spec-kit", Component{Version: "0.0.72", InstalledFrom: "vendored", InstallPath: "/tmp/.specify"})
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(VersionLockBackupPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("first Save() created a backup: %v", err)
+	}
+
+	// The second save keeps the first as the previous generation
+	lock.SetComponent("spec-kit", Component{Version: "0.0.80", InstalledFrom: "vendored", InstallPath: "/tmp/.specify"})
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a write interrupted by a crash
+	if err := os.WriteFile(path, []byte(`{"version": "1.0", "installation_`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadVersionLock(path)
+	if err != nil {
+		t.Fatalf("LoadVersionLock() error = %v", err)
+	}
+	if comp, _ := loaded.GetComponent("spec-kit"); comp.Version != "0.0.72" {
+		t.Errorf("recovered spec-kit version = %s, want 0.0.72", comp.Version)
+	}
+
+	// The damaged file is named, and left alone until the lock is saved
+	if loaded.Recovered == nil || loaded.Recovered.Path != path {
+		t.Fatalf("Recovered = %v, want the damaged lock %s", loaded.Recovered, path)
+	}
+	if !strings.Contains(loaded.Recovered.Error(), path) {
+		t.Errorf("Recovered.Error() = %q, want it to name %s", loaded.Recovered.Error(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "0.0.72") {
+		t.Errorf("LoadVersionLock() wrote the recovered lock")
+	}
+
+	if err := loaded.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Recovered != nil {
+		t.Errorf("Recovered = %v after Save(), want nil", loaded.Recovered)
+	}
+	reloaded, err := LoadVersionLock(path)
+	if err != nil {
+		t.Fatalf("LoadVersionLock() after Save() error = %v", err)
+	}
+	if reloaded.Recovered != nil {
+		t.Errorf("saved lock loaded as recovered: %v", reloaded.Recovered)
+	}
+
+	// A damaged lock without a usable backup is still an error
+	os.Remove(VersionLockBackupPath(path))
+	os.WriteFile(path, []byte("{"), 0644)
+	if _, err := LoadVersionLock(path); err == nil || !strings.Contains(err.Error(), "parse") {
+		t.Errorf("LoadVersionLock() error = %v, want parse error", err)
+	}
+}
+
+// baselineLock is a version lock as written by the first release, which
+// recorded schema 1.0 without file ownership
+const baselineLock = `{
+  "version": "1.0",
+  "installation_id": "550e8400-e29b-41d4-a716-446655440000",
+  "installed_at": "2025-10-22T12:00:00Z",
+  "last_verified": "2025-10-22T12:00:00Z",
+  "components": {
+    "spec-kit": {
+      "version": "0.0.72",
+      "installed_from": "vendored",
+      "install_path": "/home/user/spec-kit-agents/.specify"
+    },
+    "spec-kit-agents": {
+      "version": "2.0.0",
+      "installed_from": "git",
+      "install_path": "/home/user/spec-kit-agents"
+    }
+  },
+  "history": [
+    {
+      "timestamp": "2025-10-22T12:00:00Z",
+      "action": "install",
+      "component": "all",
+      "version": "2.0.0",
+      "status": "success"
+    }
+  ]
+}`
+
+func TestLoadVersionLock_MigratesBaselineLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".version-lock.json")
+	if err := os.WriteFile(path, []byte(baselineLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := LoadVersionLock(path)
+	if err != nil {
+		t.Fatalf("LoadVersionLock() error = %v", err)
+	}
+	if lock.Version != CurrentLockVersion {
+		t.Errorf("Version = %s, want %s", lock.Version, CurrentLockVersion)
+	}
+	if comp, err := lock.GetComponent("spec-kit-agents"); err != nil || comp.Version != "2.0.0" {
+		t.Errorf("spec-kit-agents component = %+v, %v", comp, err)
+	}
+	if len(lock.History) != 1 || lock.History[0].Component != "all" {
+		t.Errorf("History = %+v, want the install entry", lock.History)
+	}
+
+	// Loading migrates in memory only
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != baselineLock {
+		t.Errorf("LoadVersionLock() wrote the lock:\n%s", data)
+	}
+
+	// Saving writes the migrated lock and keeps the original as backup
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"version": "`+CurrentLockVersion+`"`) {
+		t.Errorf("migrated lock was not saved:\n%s", data)
+	}
+	backup, err := os.ReadFile(VersionLockBackupPath(path))
+	if err != nil {
+		t.Fatalf("original lock not kept as backup: %v", err)
+	}
+	if string(backup) != baselineLock {
+		t.Errorf("backup = %s, want the original lock", backup)
+	}
+}
+
+func TestLoadVersionLock_MigratesFileSources(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".version-lock.json")
+	hash := strings.Repeat("a", 64)
+	p := filepath.ToSlash(filepath.Join(dir, "p"))
+	claude := filepath.ToSlash(filepath.Join(dir, ".claude"))
+	original := `{
+  "version": "1.0",
+  "installation_id": "550e8400-e29b-41d4-a716-446655440000",
+  "installed_at": "2025-10-22T12:00:00Z",
+  "components": {
+    "spec-kit": {"version": "0.0.72", "installed_from": "git", "install_path": "` + p + `/.specify"},
+    "spec-kit-agents": {"version": "2.0.0", "installed_from": "git", "install_path": "` + p + `"}
+  },
+  "files": [
+    {"path": "` + claude + `/commands/speckit.plan.md", "size": 1, "sha256": "` + hash + `", "source": "` + p + `/.specify/templates/commands/plan.md"},
+    {"path": "` + p + `/.specify/scripts/bash/common.sh", "size": 1, "sha256": "` + hash + `", "source": "` + p + `/.cache/spec-kit-0.0.72/scripts/bash/common.sh"},
+    {"path": "` + claude + `/agents/cat-documentation.md", "size": 1, "sha256": "` + hash + `", "source": "agents/core/documentation.md"}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := LoadVersionLock(path)
+	if err != nil {
+		t.Fatalf("LoadVersionLock() error = %v", err)
+	}
+
+	want := []string{
+		".specify/templates/commands/plan.md",
+		".specify/scripts/bash/common.sh",
+		"agents/core/documentation.md",
+	}
+	for i, file := range lock.Files {
+		if file.Source != want[i] {
+			t.Errorf("source of %s = %q, want %q", file.Path, file.Source, want[i])
+		}
+	}
+}
+
+func TestLoadVersionLock_NewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".version-lock.json")
+
+	lock := NewVersionLock()
+	lock.SetComponent("spec-kit", Component{Version: "0.0.72", InstalledFrom: "vendored", InstallPath: "/tmp/.specify"})
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	newer := `{"version": "9.0", "installation_id": "550e8400-e29b-41d4-a716-446655440000", "future_field": true}`
+	if err := os.WriteFile(path, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadVersionLock(path)
+	var unsupported *UnsupportedLockVersionError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("LoadVersionLock() error = %v, want UnsupportedLockVersionError", err)
+	}
+	if !strings.Contains(err.Error(), "please upgrade spec-kit-agents") {
+		t.Errorf("error = %q, want upgrade hint", err.Error())
+	}
+
+	// The newer lock is left alone rather than replaced by its backup
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != newer {
+		t.Errorf("newer lock was modified:\n%s", data)
+	}
+}
@@ -4,17 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 )
 
 // Manifest represents the version manifest for claude-agent-templates
 type Manifest struct {
-	Version      string                 `json:"version"`
-	Name         string                 `json:"name"`
-	Dependencies map[string]Dependency  `json:"dependencies"`
-	UpdatePolicy string                 `json:"update_policy,omitempty"`
-	LastUpdated  string                 `json:"last_updated,omitempty"`
+	Version          string                `json:"version"`
+	Name             string                `json:"name"`
+	TemplatesVersion string                `json:"templates_version,omitempty"`
+	Dependencies     map[string]Dependency `json:"dependencies"`
+	UpdatePolicy     string                `json:"update_policy,omitempty"`
+	LastUpdated      string                `json:"last_updated,omitempty"`
 }
 
 // Dependency represents a dependency in the version manifest
@@ -23,6 +26,10 @@ type Dependency struct {
 	Source        string        `json:"source"`
 	InstallPath   string        `json:"install_path"`
 	Integrity     string        `json:"integrity,omitempty"`
+	Repository    string        `json:"repository,omitempty"` // Git remote URL (source: git)
+	Ref           string        `json:"ref,omitempty"`        // Tag or commit to check out (source: git)
+	Archive       string        `json:"archive,omitempty"`    // Local .tar.gz, .tgz or .zip file (source: archive)
+	Subdir        string        `json:"subdir,omitempty"`     // Directory within the repository or archive to install (source: git, archive)
 	Compatibility Compatibility `json:"compatibility,omitempty"`
 }
 
@@ -40,6 +47,11 @@ func LoadManifest(path string) (*Manifest, error) {
 		return nil, fmt.Errorf("failed to read manifest: %w", err)
 	}
 
+	return ParseManifest(data)
+}
+
+// ParseManifest parses and validates a version manifest from JSON
+func ParseManifest(data []byte) (*Manifest, error) {
 	var manifest Manifest
 	if err := json.Unmarshal(data, &manifest); err != nil {
 		return nil, fmt.Errorf("failed to parse manifest JSON: %w", err)
@@ -60,7 +72,7 @@ func (m *Manifest) SaveManifest(path string) error {
 		return fmt.Errorf("failed to marshal manifest: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 
@@ -80,6 +92,14 @@ func (m *Manifest) Validate() error {
 		return fmt.Errorf("manifest name is required")
 	}
 
+	// Validate templates version (semver format: X.Y.Z, optionally prefixed with v)
+	if m.TemplatesVersion != "" {
+		templatesVersionPattern := regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+$`)
+		if !templatesVersionPattern.MatchString(m.TemplatesVersion) {
+			return fmt.Errorf("invalid templates_version format: %s (expected X.Y.Z)", m.TemplatesVersion)
+		}
+	}
+
 	// Validate dependencies
 	if len(m.Dependencies) == 0 {
 		return fmt.Errorf("manifest must have at least one dependency")
@@ -119,9 +139,32 @@ func (d *Dependency) Validate(name string) error {
 	}
 
 	// Validate source
-	validSources := map[string]bool{"vendored": true, "git": true, "npm": true}
+	validSources := map[string]bool{"vendored": true, "git": true, "archive": true, "npm": true}
 	if !validSources[d.Source] {
-		return fmt.Errorf("invalid source: %s (must be vendored, git, or npm)", d.Source)
+		return fmt.Errorf("invalid source: %s (must be vendored, git, archive, or npm)", d.Source)
+	}
+
+	// Validate git and archive source settings
+	if d.Source == "git" {
+		if d.Repository == "" {
+			return fmt.Errorf("repository is required for git source")
+		}
+		if d.Ref == "" {
+			return fmt.Errorf("ref is required for git source (tag or commit)")
+		}
+		// Either would be read as an option by git
+		if strings.HasPrefix(d.Repository, "-") {
+			return fmt.Errorf("invalid repository: %s (must not start with -)", d.Repository)
+		}
+		if strings.HasPrefix(d.Ref, "-") {
+			return fmt.Errorf("invalid ref: %s (must not start with -)", d.Ref)
+		}
+	}
+	if d.Source == "archive" && d.Archive == "" {
+		return fmt.Errorf("archive is required for archive source")
+	}
+	if d.Subdir != "" && !filepath.IsLocal(d.Subdir) {
+		return fmt.Errorf("invalid subdir: %s (must be a relative path inside the repository or archive)", d.Subdir)
 	}
 
 	// Validate install path
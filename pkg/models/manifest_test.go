@@ -61,6 +61,23 @@ func TestManifest_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "manifest name is required",
 		},
+		{
+			name: "invalid templates version",
+			manifest: &Manifest{
+				Version:          "1.0",
+				Name:             "claude-agent-templates",
+				TemplatesVersion: "2.0",
+				Dependencies: map[string]Dependency{
+					"spec-kit": {
+						Version:     "0.0.72",
+						Source:      "vendored",
+						InstallPath: ".specify",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid templates_version format",
+		},
 		{
 			name: "no dependencies",
 			manifest: &Manifest{
@@ -190,6 +207,105 @@ func TestDependency_Validate(t *testing.T) {
 			depName: "spec-kit",
 			wantErr: false,
 		},
+		{
+			name: "valid git source",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "git",
+				InstallPath: ".specify",
+				Repository:  "https://github.com/github/spec-kit.git",
+				Ref:         "v0.0.72",
+			},
+			depName: "spec-kit",
+			wantErr: false,
+		},
+		{
+			name: "git source without repository",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "git",
+				InstallPath: ".specify",
+				Ref:         "v0.0.72",
+			},
+			depName: "spec-kit",
+			wantErr: true,
+			errMsg:  "repository is required",
+		},
+		{
+			name: "git source without ref",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "git",
+				InstallPath: ".specify",
+				Repository:  "file:///srv/git/spec-kit.git",
+			},
+			depName: "spec-kit",
+			wantErr: true,
+			errMsg:  "ref is required",
+		},
+		{
+			name: "git repository that looks like an option",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "git",
+				InstallPath: ".specify",
+				Repository:  "--upload-pack=touch /tmp/pwned",
+				Ref:         "v0.0.72",
+			},
+			depName: "spec-kit",
+			wantErr: true,
+			errMsg:  "invalid repository",
+		},
+		{
+			name: "git ref that looks like an option",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "git",
+				InstallPath: ".specify",
+				Repository:  "file:///srv/git/spec-kit.git",
+				Ref:         "--output=/tmp/pwned",
+			},
+			depName: "spec-kit",
+			wantErr: true,
+			errMsg:  "invalid ref",
+		},
+		{
+			name: "valid archive source",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "archive",
+				InstallPath: ".specify",
+				Archive:     "vendor/spec-kit-0.0.72.tar.gz",
+				Subdir:      "spec-kit-0.0.72",
+			},
+			depName: "spec-kit",
+			wantErr: false,
+		},
+		{
+			name: "archive source without archive",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "archive",
+				InstallPath: ".specify",
+			},
+			depName: "spec-kit",
+			wantErr: true,
+			errMsg:  "archive is required",
+		},
+		{
+			name: "subdir outside repository",
+			dependency: &Dependency{
+				Version:     "0.0.72",
+				Source:      "git",
+				InstallPath: ".specify",
+				Repository:  "file:///srv/git/spec-kit.git",
+				Ref:         "v0.0.72",
+				Subdir:      "../outside",
+			},
+			depName: "spec-kit",
+			wantErr: true,
+			errMsg:  "invalid subdir",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CurrentLockVersion is the version lock schema written by this release.
+// Locks with an older schema are migrated when loaded; locks with a newer one
+// were written by a newer release and are refused.
+const CurrentLockVersion = "1.1"
+
+// lockMigration upgrades a version lock from one schema version to the next.
+// Migrations operate on the decoded JSON so that they can see fields that no
+// longer exist in VersionLock.
+type lockMigration struct {
+	from    string
+	to      string
+	migrate func(lock map[string]any) error
+}
+
+// lockMigrations lists the schema migrations in order
+var lockMigrations = []lockMigration{
+	{from: "1.0", to: "1.1", migrate: relativeFileSources},
+}
+
+// ErrUnsupportedLockVersion is matched by UnsupportedLockVersionError
+var ErrUnsupportedLockVersion = errors.New("unsupported version lock schema")
+
+// UnsupportedLockVersionError is returned for a version lock written by a
+// newer release with a schema this release does not understand
+type UnsupportedLockVersionError struct {
+	Version   string
+	Supported string
+}
+
+// Is makes errors.Is(err, ErrUnsupportedLockVersion) true for an
+// UnsupportedLockVersionError
+func (e *UnsupportedLockVersionError) Is(target error) bool {
+	return target == ErrUnsupportedLockVersion
+}
+
+func (e *UnsupportedLockVersionError) Error() string {
+	return fmt.Sprintf("version lock schema %s is newer than the supported schema %s; please upgrade spec-kit-agents", e.Version, e.Supported)
+}
+
+// migrateVersionLock upgrades version lock JSON to CurrentLockVersion
+func migrateVersionLock(data []byte) ([]byte, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse version lock JSON: %w", err)
+	}
+
+	schema, _ := raw["version"].(string)
+	order, err := compareLockVersions(schema, CurrentLockVersion)
+	if err != nil {
+		return nil, fmt.Errorf("version lock validation failed: %w", err)
+	}
+	if order > 0 {
+		return nil, &UnsupportedLockVersionError{Version: schema, Supported: CurrentLockVersion}
+	}
+	if order == 0 {
+		return data, nil
+	}
+
+	for schema != CurrentLockVersion {
+		migration, ok := findLockMigration(schema)
+		if !ok {
+			return nil, fmt.Errorf("no migration from version lock schema %s", schema)
+		}
+		if err := migration.migrate(raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate version lock from schema %s to %s: %w", migration.from, migration.to, err)
+		}
+		schema = migration.to
+		raw["version"] = schema
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated version lock: %w", err)
+	}
+	return migrated, nil
+}
+
+// findLockMigration returns the migration from the given schema version
+func findLockMigration(from string) (lockMigration, bool) {
+	for _, migration := range lockMigrations {
+		if migration.from == from {
+			return migration, true
+		}
+	}
+	return lockMigration{}, false
+}
+
+// compareLockVersions compares two X.Y schema versions, returning -1, 0 or 1
+func compareLockVersions(a, b string) (int, error) {
+	aMajor, aMinor, err := parseLockVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bMajor, bMinor, err := parseLockVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	if aMajor != bMajor {
+		return cmp.Compare(aMajor, bMajor), nil
+	}
+	return cmp.Compare(aMinor, bMinor), nil
+}
+
+// parseLockVersion splits an X.Y schema version into its numbers
+func parseLockVersion(v string) (int, int, error) {
+	majorStr, minorStr, ok := strings.Cut(v, ".")
+	major, majorErr := strconv.Atoi(majorStr)
+	minor, minorErr := strconv.Atoi(minorStr)
+	if !ok || majorErr != nil || minorErr != nil || major < 0 || minor < 0 {
+		return 0, 0, fmt.Errorf("invalid lock version format: %s (expected X.Y)", v)
+	}
+	return major, minor, nil
+}
+
+// specKitTree is the directory of a source tree that holds spec-kit, which
+// file sources of spec-kit files and commands are relative to
+const specKitTree = ".specify"
+
+// relativeFileSources migrates schema 1.0 to 1.1. Releases writing 1.0
+// recorded the source of a spec-kit file or command as the absolute path it
+// was copied from: the installed .specify/ directory for commands, or the
+// cache spec-kit was fetched into. 1.1 records it relative to the spec-kit
+// tree, such as .specify/templates/commands/plan.md, so that update reads the
+// new release of it. Locks of releases that did not record files, such as
+// the first, only have their schema version raised.
+func relativeFileSources(lock map[string]any) error {
+	files, _ := lock["files"].([]any)
+	if len(files) == 0 {
+		return nil
+	}
+
+	components, _ := lock["components"].(map[string]any)
+	specKit, _ := components["spec-kit"].(map[string]any)
+	specKitDir, _ := specKit["install_path"].(string)
+	if specKitDir == "" {
+		return fmt.Errorf("spec-kit component has no install_path")
+	}
+
+	// relativeTo returns name relative to the installed spec-kit directory
+	relativeTo := func(name string) (string, bool) {
+		rel, err := filepath.Rel(filepath.FromSlash(specKitDir), filepath.FromSlash(name))
+		if err != nil || !filepath.IsLocal(rel) {
+			return "", false
+		}
+		return path.Join(specKitTree, filepath.ToSlash(rel)), true
+	}
+
+	for _, item := range files {
+		file, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		source, _ := file["source"].(string)
+		if !filepath.IsAbs(filepath.FromSlash(source)) {
+			continue
+		}
+
+		// A command was copied from the installed templates; a spec-kit
+		// file fetched into the cache is named by where it was installed
+		if rel, ok := relativeTo(source); ok {
+			file["source"] = rel
+		} else if installed, _ := file["path"].(string); installed != "" {
+			if rel, ok := relativeTo(installed); ok {
+				file["source"] = rel
+			}
+		}
+	}
+
+	return nil
+}